@@ -0,0 +1,382 @@
+package datadirectory
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Ordered metadata header values that are written to, and read from, BagIt
+// bag-info.txt, along with their labels.
+var bagInfoLabels = [][2]string{
+	{"organization", "Source-Organization"},
+	{"cdm", "CDM"},
+	{"cdm-version", "CDM-Version"},
+	{"etl", "ETL"},
+	{"data-version", "Data-Version"},
+}
+
+// ExportBag writes the DataDirectory as a BagIt (RFC 8493) bag in bagPath,
+// which must not exist yet. The data files are copied into the data/ payload
+// directory and verified against their checksums on the way, which are used
+// for manifest-sha256.txt. The metadata is kept as the metadata.csv tag file.
+func (d *DataDirectory) ExportBag(bagPath string) error {
+
+	var (
+		manifest bytes.Buffer
+		metadata bytes.Buffer
+		bagInfo  bytes.Buffer
+		oxum     int64
+		err      error
+	)
+
+	if err = os.Mkdir(bagPath, 0755); err != nil {
+		return err
+	}
+
+	// Copy the payload, building the manifest.
+	for _, recordMap := range d.RecordMaps {
+
+		var (
+			name  string
+			size  int64
+			start = time.Now()
+		)
+
+		if name, err = archiveName(recordMap["filename"]); err != nil {
+			return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+		}
+
+		if size, err = d.copyDataFile(filepath.Join(bagPath, "data", filepath.FromSlash(name)), recordMap["filename"], recordMap["checksum"]); err != nil {
+			return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+		}
+
+		d.logger().Info("copied data file to bag", "file", recordMap["filename"], "table", recordMap["table"], "bytes", size, "duration", time.Since(start))
+
+		oxum += size
+
+		fmt.Fprintf(&manifest, "%s  %s\n", recordMap["checksum"], bagEncodePath("data/"+name))
+	}
+
+	// Fill bag-info.txt from the DataDirectory, falling back to the records.
+	for _, label := range bagInfoLabels {
+
+		var val = d.headerDefault(label[0])
+
+		if val != "" {
+			fmt.Fprintf(&bagInfo, "%s: %s\n", label[1], val)
+		}
+	}
+
+	fmt.Fprintf(&bagInfo, "Bagging-Date: %s\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&bagInfo, "Payload-Oxum: %d.%d\n", oxum, len(d.RecordMaps))
+
+	if err = d.WriteMetadata(&metadata); err != nil {
+		return err
+	}
+
+	// Write the tag files, followed by the tag manifest covering them.
+	return writeBagTagFiles(bagPath, [][2]string{
+		{"bagit.txt", "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"},
+		{"bag-info.txt", bagInfo.String()},
+		{"manifest-sha256.txt", manifest.String()},
+		{"metadata.csv", metadata.String()},
+	})
+}
+
+// headerDefault returns the DataDirectory attribute for a header value, or
+// the value of the first record if the attribute is not set.
+func (d *DataDirectory) headerDefault(val string) string {
+
+	var attr string
+
+	switch val {
+	case "organization":
+		attr = d.Site
+	case "cdm":
+		attr = d.Model
+	case "cdm-version":
+		attr = d.ModelVersion
+	case "etl":
+		attr = d.Etl
+	case "data-version":
+		attr = d.DataVersion
+	}
+
+	if attr == "" && len(d.RecordMaps) > 0 {
+		attr = d.RecordMaps[0][val]
+	}
+
+	return attr
+}
+
+// writeBagTagFiles writes each name and content pair to bagPath, followed by
+// a tagmanifest-sha256.txt listing them.
+func writeBagTagFiles(bagPath string, tagFiles [][2]string) error {
+
+	var (
+		tagManifest bytes.Buffer
+		err         error
+	)
+
+	for _, tagFile := range tagFiles {
+
+		if err = os.WriteFile(filepath.Join(bagPath, tagFile[0]), []byte(tagFile[1]), 0644); err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256([]byte(tagFile[1]))
+		fmt.Fprintf(&tagManifest, "%s  %s\n", hex.EncodeToString(sum[:]), tagFile[0])
+	}
+
+	return os.WriteFile(filepath.Join(bagPath, "tagmanifest-sha256.txt"), tagManifest.Bytes(), 0644)
+}
+
+// copyDataFile copies the data file with the passed filename, relative to the
+// data directory, to a new file at dst, creating parent directories as
+// needed, and returns the number of bytes copied. An error is returned if the
+// copied bytes do not match the passed checksum.
+func (d *DataDirectory) copyDataFile(dst string, filename string, checksum string) (int64, error) {
+
+	var (
+		srcFile fs.File
+		dstFile *os.File
+		sum     hash.Hash
+		size    int64
+		err     error
+	)
+
+	if srcFile, err = d.openFile(filename); err != nil {
+		return 0, err
+	}
+
+	defer srcFile.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+
+	if dstFile, err = os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644); err != nil {
+		return 0, err
+	}
+
+	sum = sha256.New()
+
+	if size, err = io.Copy(io.MultiWriter(dstFile, sum), srcFile); err != nil {
+		dstFile.Close()
+		return 0, err
+	}
+
+	if err = dstFile.Close(); err != nil {
+		return 0, err
+	}
+
+	if checksum != hex.EncodeToString(sum.Sum(nil)) {
+		return 0, fmt.Errorf("file '%s' checksum does not match", filename)
+	}
+
+	return size, nil
+}
+
+// ImportBag reads the BagIt bag in bagPath into the DataDirectory, pointing
+// DirPath at its data/ payload directory. If the bag includes a metadata.csv
+// tag file, as written by ExportBag, it is read and its checksums are checked
+// against the manifest. Otherwise, records are built from manifest-sha256.txt
+// and bag-info.txt, with table names derived from the file names. The payload
+// checksums themselves are verified by Validate.
+func (d *DataDirectory) ImportBag(bagPath string) error {
+
+	var (
+		manifest map[string]string
+		names    []string
+		bagInfo  map[string]string
+		err      error
+	)
+
+	if _, err = os.Stat(filepath.Join(bagPath, "bagit.txt")); err != nil {
+		return fmt.Errorf("'%s' is not a BagIt bag: %s", bagPath, err)
+	}
+
+	if manifest, names, err = readBagManifest(filepath.Join(bagPath, "manifest-sha256.txt")); err != nil {
+		return err
+	}
+
+	d.DirPath = filepath.Join(bagPath, "data")
+	d.FS = nil
+	d.FilePath = filepath.Join(bagPath, "metadata.csv")
+	d.RecordMaps = make([]map[string]string, 0)
+
+	// Prefer the metadata tag file when present.
+	if _, err = os.Stat(d.FilePath); err == nil {
+
+		if err = d.ReadMetadataFromFile(); err != nil {
+			return err
+		}
+
+		if len(d.RecordMaps) != len(manifest) {
+			return fmt.Errorf("metadata.csv lists %d files but the bag manifest lists %d", len(d.RecordMaps), len(manifest))
+		}
+
+		for _, recordMap := range d.RecordMaps {
+			if manifest[path.Clean(filepath.ToSlash(recordMap["filename"]))] != recordMap["checksum"] {
+				return fmt.Errorf("line '%s' file '%s' checksum does not match the bag manifest", recordMap["line"], recordMap["filename"])
+			}
+		}
+
+		return nil
+	}
+
+	if bagInfo, err = readBagInfo(filepath.Join(bagPath, "bag-info.txt")); err != nil {
+		return err
+	}
+
+	// Fill missing DataDirectory attributes from bag-info.txt so that table
+	// names can be looked up.
+	if d.Model == "" {
+		d.Model = strings.ToLower(bagInfo["CDM"])
+	}
+
+	if d.ModelVersion == "" {
+		d.ModelVersion = strings.ToLower(bagInfo["CDM-Version"])
+	}
+
+	for _, name := range names {
+
+		var (
+			table     string
+			recordMap map[string]string
+		)
+
+		if table, err = d.tableForFile(name); err != nil {
+			return err
+		}
+
+		recordMap = d.newRecordMap(filepath.FromSlash(name), &fileHash{checksum: manifest[name], size: -1, rows: -1}, table)
+
+		for _, label := range bagInfoLabels {
+
+			val, ok := bagInfo[label[1]]
+
+			if !ok {
+				continue
+			}
+
+			if label[0] == "organization" || label[0] == "etl" {
+				recordMap[label[0]] = val
+			} else {
+				recordMap[label[0]] = strings.ToLower(val)
+			}
+		}
+
+		d.appendRecord(recordMap)
+	}
+
+	return nil
+}
+
+// readBagManifest reads a BagIt payload manifest, returning a map of payload
+// paths, relative to data/, to checksums along with the paths in order.
+func readBagManifest(manifestPath string) (map[string]string, []string, error) {
+
+	var (
+		file     *os.File
+		scanner  *bufio.Scanner
+		manifest map[string]string
+		names    []string
+		err      error
+	)
+
+	if file, err = os.Open(manifestPath); err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	manifest = make(map[string]string)
+	scanner = bufio.NewScanner(file)
+
+	for scanner.Scan() {
+
+		var fields = strings.SplitN(scanner.Text(), " ", 2)
+
+		if len(fields) != 2 {
+			return nil, nil, fmt.Errorf("invalid manifest line: %s", scanner.Text())
+		}
+
+		name := path.Clean(bagDecodePath(strings.TrimLeft(fields[1], " ")))
+
+		if !strings.HasPrefix(name, "data/") {
+			return nil, nil, fmt.Errorf("manifest path '%s' is outside the payload directory", name)
+		}
+
+		name = strings.TrimPrefix(name, "data/")
+		manifest[name] = strings.ToLower(fields[0])
+		names = append(names, name)
+	}
+
+	return manifest, names, scanner.Err()
+}
+
+// readBagInfo reads the labels and values of a bag-info.txt file. Indented
+// continuation lines are joined to the preceding value.
+func readBagInfo(bagInfoPath string) (map[string]string, error) {
+
+	var (
+		data    []byte
+		bagInfo map[string]string
+		label   string
+		err     error
+	)
+
+	if data, err = os.ReadFile(bagInfoPath); err != nil {
+		return nil, err
+	}
+
+	bagInfo = make(map[string]string)
+
+	for _, line := range strings.Split(string(data), "\n") {
+
+		line = strings.TrimRight(line, "\r")
+
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && label != "" {
+			bagInfo[label] += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid bag-info line: %s", line)
+		}
+
+		label = strings.TrimSpace(parts[0])
+		bagInfo[label] = strings.TrimSpace(parts[1])
+	}
+
+	return bagInfo, nil
+}
+
+// bagEncodePath percent-encodes the characters RFC 8493 requires to be
+// encoded in manifest paths.
+func bagEncodePath(name string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(name)
+}
+
+// bagDecodePath reverses bagEncodePath.
+func bagDecodePath(name string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%").Replace(name)
+}
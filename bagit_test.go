@@ -0,0 +1,103 @@
+package datadirectory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestExportImportBag(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		bagPath string
+		err     error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	bagPath = filepath.Join(t.TempDir(), "bag")
+
+	if err = d.ExportBag(bagPath); err != nil {
+		t.Fatalf("ExportBag(): error in basic function: %s", err)
+	}
+
+	for _, name := range []string{"bagit.txt", "bag-info.txt", "manifest-sha256.txt", "tagmanifest-sha256.txt", "data/location.csv"} {
+		if _, err = os.Stat(filepath.Join(bagPath, name)); err != nil {
+			t.Errorf("ExportBag(): expected file '%s' not written: %s", name, err)
+		}
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ImportBag(bagPath); err != nil {
+		t.Fatalf("ImportBag(): error in basic function: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Errorf("ImportBag(): expected number of RecordMaps (3) does not match actual length (%d)", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after ImportBag(): %s", err)
+	}
+
+}
+
+func TestImportBagWithoutMetadata(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		bagPath string
+		err     error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	bagPath = filepath.Join(t.TempDir(), "bag")
+
+	if err = d.ExportBag(bagPath); err != nil {
+		t.Fatalf("ExportBag(): error in basic function: %s", err)
+	}
+
+	if err = os.Remove(filepath.Join(bagPath, "metadata.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ImportBag(bagPath); err != nil {
+		t.Fatalf("ImportBag(): error in basic function: %s", err)
+	}
+
+	for _, recordMap := range d.RecordMaps {
+		if recordMap["organization"] != "org" || recordMap["cdm-version"] != "2.1.0" || recordMap["table"] == "" {
+			t.Errorf("ImportBag(): record not filled from bag-info.txt: %v", recordMap)
+		}
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after ImportBag(): %s", err)
+	}
+
+}
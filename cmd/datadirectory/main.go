@@ -0,0 +1,255 @@
+// Command datadirectory provides command line access to data directory
+// operations that do not require the data models service.
+//
+// Usage:
+//
+//	datadirectory diff [-json] <a> <b>
+//	datadirectory scan [-json] [-allowlist file] [-detectors file] [-columns patterns] <dir>
+//
+// Each of <a> and <b> is either a metadata file or a data directory
+// containing one, as metadata.csv, metadata.json or metadata.yaml. The scan command flags identifiers in the data files
+// listed in the metadata of <dir>.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/infomodels/datadirectory"
+)
+
+const usage = `usage: datadirectory <command> [arguments]
+
+commands:
+  diff [-json] <a> <b>    compare two metadata files or data directories
+  scan [-json] <dir>      flag identifiers in the data files of a data directory
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "diff":
+		if err := diff(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "datadirectory: %s\n", err)
+			os.Exit(1)
+		}
+	case "scan":
+		if err := scan(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "datadirectory: %s\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// diff runs the diff command, exiting with status 3 if the two metadata sets
+// differ.
+func diff(args []string) error {
+
+	var (
+		flags     *flag.FlagSet
+		asJSON    *bool
+		a         *datadirectory.DataDirectory
+		b         *datadirectory.DataDirectory
+		changeset *datadirectory.Changeset
+		err       error
+	)
+
+	flags = flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON = flags.Bool("json", false, "write the changeset as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("diff requires two metadata files or data directories")
+	}
+
+	if a, err = readMetadata(flags.Arg(0)); err != nil {
+		return err
+	}
+
+	if b, err = readMetadata(flags.Arg(1)); err != nil {
+		return err
+	}
+
+	changeset = datadirectory.Diff(a, b)
+
+	if *asJSON {
+		err = changeset.WriteJSON(os.Stdout)
+	} else {
+		err = changeset.WriteText(os.Stdout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !changeset.Empty() {
+		os.Exit(3)
+	}
+
+	return nil
+}
+
+// scan runs the scan command, exiting with status 3 if anything is flagged.
+func scan(args []string) error {
+
+	var (
+		flags     *flag.FlagSet
+		asJSON    *bool
+		allowlist *string
+		detectors *string
+		columns   *string
+		d         *datadirectory.DataDirectory
+		opts      datadirectory.ScanOptions
+		findings  []*datadirectory.ScanFinding
+		err       error
+	)
+
+	flags = flag.NewFlagSet("scan", flag.ExitOnError)
+	asJSON = flags.Bool("json", false, "write the findings as JSON")
+	allowlist = flags.String("allowlist", "", "file of values to ignore, one per line")
+	detectors = flags.String("detectors", "", "JSON file of detectors to use instead of the defaults")
+	columns = flags.String("columns", "", "comma separated column patterns to scan instead of *_source_value")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("scan requires a data directory")
+	}
+
+	if d, err = readMetadata(flags.Arg(0)); err != nil {
+		return err
+	}
+
+	if *allowlist != "" {
+
+		var file *os.File
+
+		if file, err = os.Open(*allowlist); err != nil {
+			return err
+		}
+
+		opts.Allowlist, err = datadirectory.ReadAllowlist(file)
+		file.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if *detectors != "" {
+
+		var file *os.File
+
+		if file, err = os.Open(*detectors); err != nil {
+			return err
+		}
+
+		opts.Detectors, err = datadirectory.ReadDetectors(file)
+		file.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if *columns != "" {
+		opts.Columns = strings.Split(*columns, ",")
+	}
+
+	if findings, err = d.Scan(opts); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(findings)
+	} else {
+		for _, finding := range findings {
+			fmt.Printf("%s.%s: %d %s\n", finding.Table, finding.Column, finding.Count, finding.Detector)
+			for _, match := range finding.Examples {
+				fmt.Printf("  %s:%d: %s\n", match.Filename, match.Line, match.Sample)
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		os.Exit(3)
+	}
+
+	return nil
+}
+
+// Metadata file names looked for in a data directory, in order.
+var metadataFiles = []string{
+	"metadata." + string(datadirectory.CSVFormat),
+	"metadata." + string(datadirectory.JSONFormat),
+	"metadata." + string(datadirectory.YAMLFormat),
+	"metadata.yml",
+}
+
+// readMetadata reads the metadata file at path or, if path is a directory,
+// the first of the metadataFiles in it. The format follows the file
+// extension, as in ReadMetadataFromFile.
+func readMetadata(path string) (*datadirectory.DataDirectory, error) {
+
+	var (
+		fi  os.FileInfo
+		d   *datadirectory.DataDirectory
+		err error
+	)
+
+	if fi, err = os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	d = &datadirectory.DataDirectory{
+		DirPath:  path,
+		FilePath: path,
+	}
+
+	if !fi.IsDir() {
+		d.DirPath = filepath.Dir(path)
+	} else if d.FilePath, err = findMetadataFile(path); err != nil {
+		return nil, err
+	}
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// findMetadataFile returns the path of the first of the metadataFiles in the
+// directory.
+func findMetadataFile(dir string) (string, error) {
+
+	for _, name := range metadataFiles {
+
+		path := filepath.Join(dir, name)
+
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("no metadata file found in '%s'", dir)
+}
@@ -0,0 +1,218 @@
+package datadirectory
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Data files may be stored compressed, identified by an extension following
+// ".csv". The map values are the compression recorded in the metadata.
+var compressionExts = map[string]string{
+	".gz":  "gzip",
+	".bz2": "bzip2",
+	".zst": "zstd",
+}
+
+// splitDataFileName splits a data file name into its base name, without
+// ".csv" and any compression extension, and its compression. ok is false if
+// the name is not that of a csv file.
+func splitDataFileName(name string) (base string, compression string, ok bool) {
+
+	var ext = filepath.Ext(name)
+
+	if c, found := compressionExts[ext]; found {
+		compression = c
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	if filepath.Ext(name) != ".csv" {
+		return "", "", false
+	}
+
+	return strings.TrimSuffix(filepath.Base(name), ".csv"), compression, true
+}
+
+// recordCompression returns the compression of the data file for a record,
+// falling back to the file extension if the metadata does not include it.
+func recordCompression(recordMap map[string]string) string {
+
+	if recordMap["compression"] != "" {
+		return recordMap["compression"]
+	}
+
+	_, compression, _ := splitDataFileName(recordMap["filename"])
+
+	return compression
+}
+
+// checkCompression returns an error if the passed compression is not empty
+// and not supported.
+func checkCompression(compression string) error {
+
+	if compression == "" {
+		return nil
+	}
+
+	for _, c := range compressionExts {
+		if compression == c {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("compression '%s' not supported", compression)
+}
+
+// hashDataFile writes the stored bytes of a data file to sum and its csv
+// content to rows. If the file is compressed, it is decompressed in the same
+// pass, which also reports corrupt files.
+func hashDataFile(r io.Reader, sum hash.Hash, rows *csvRowCounter, compression string) error {
+
+	var (
+		decompressed io.ReadCloser
+		err          error
+	)
+
+	if compression == "" {
+		_, err = io.Copy(io.MultiWriter(sum, rows), r)
+		return err
+	}
+
+	if decompressed, err = decompressReader(io.TeeReader(r, sum), compression); err != nil {
+		return err
+	}
+
+	defer decompressed.Close()
+
+	if _, err = io.Copy(rows, decompressed); err != nil {
+		return err
+	}
+
+	// Hash anything following the compressed stream.
+	_, err = io.Copy(sum, r)
+
+	return err
+}
+
+// decompressReader wraps r in a reader that decompresses the passed
+// compression on the fly. An empty compression returns r unchanged.
+func decompressReader(r io.Reader, compression string) (io.ReadCloser, error) {
+
+	switch compression {
+	case "":
+		return io.NopCloser(r), nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case "zstd":
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression '%s'", compression)
+}
+
+// compressWriter wraps w in a writer that compresses with the passed
+// compression. An empty compression returns w unchanged. bzip2 is only
+// supported for reading, as there is no bzip2 encoder in the standard
+// library.
+func compressWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+
+	switch compression {
+	case "":
+		return nopWriteCloser{w}, nil
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	case "bzip2":
+		return nil, fmt.Errorf("compression '%s' not supported for writing", compression)
+	}
+
+	return nil, fmt.Errorf("unsupported compression '%s'", compression)
+}
+
+// nopWriteCloser adds a Close method that does nothing to a writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// dataFileReader closes a decompressing reader along with the underlying
+// data file.
+type dataFileReader struct {
+	io.ReadCloser
+	file fs.File
+}
+
+// Close closes the decompressing reader and the data file.
+func (r *dataFileReader) Close() error {
+
+	var err = r.ReadCloser.Close()
+
+	if fileErr := r.file.Close(); err == nil {
+		err = fileErr
+	}
+
+	return err
+}
+
+// openDataFile opens the data file for a record, decompressing it on the fly
+// if it is compressed.
+func (d *DataDirectory) openDataFile(recordMap map[string]string) (io.ReadCloser, error) {
+
+	var (
+		file         fs.File
+		decompressed io.ReadCloser
+		err          error
+	)
+
+	if file, err = d.openFile(recordMap["filename"]); err != nil {
+		return nil, err
+	}
+
+	if decompressed, err = decompressReader(file, recordCompression(recordMap)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("file '%s' could not be decompressed: %s", recordMap["filename"], err)
+	}
+
+	return &dataFileReader{ReadCloser: decompressed, file: file}, nil
+}
+
+// readDataHeader returns the header row of the data file for a record.
+func (d *DataDirectory) readDataHeader(recordMap map[string]string) ([]string, error) {
+
+	var (
+		dataFile io.ReadCloser
+		header   []string
+		err      error
+	)
+
+	if dataFile, err = d.openDataFile(recordMap); err != nil {
+		return nil, err
+	}
+
+	defer dataFile.Close()
+
+	if header, err = csv.NewReader(dataFile).Read(); err != nil {
+		return nil, fmt.Errorf("file '%s' header could not be read: %s", recordMap["filename"], err)
+	}
+
+	return header, nil
+}
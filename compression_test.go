@@ -0,0 +1,118 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestPopulateCompressed(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		dir    string
+		data   []byte
+		b      bytes.Buffer
+		writer *gzip.Writer
+		record map[string]string
+		err    error
+	)
+
+	dir = copyTestData(t)
+
+	// Replace location.csv with a gzip-compressed copy.
+	if data, err = os.ReadFile(filepath.Join(dir, "location.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	writer = gzip.NewWriter(&b)
+	writer.Write(data)
+	writer.Close()
+
+	if err = os.WriteFile(filepath.Join(dir, "location.csv.gz"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "location.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error in basic function: %s", err)
+	}
+
+	for _, recordMap := range d.RecordMaps {
+		if recordMap["filename"] == "location.csv.gz" {
+			record = recordMap
+		}
+	}
+
+	if record == nil {
+		t.Fatalf("PopulateMetadataFromData(): compressed file not listed")
+	}
+
+	if record["table"] != "location" {
+		t.Errorf("PopulateMetadataFromData(): expected table of compressed file ('location') does not match actual table ('%s')", record["table"])
+	}
+
+	if record["compression"] != "gzip" {
+		t.Errorf("PopulateMetadataFromData(): expected compression ('gzip') does not match actual compression ('%s')", record["compression"])
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error in basic function with compressed file: %s", err)
+	}
+
+}
+
+func TestValidateCorruptCompressed(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	dir = copyTestData(t)
+
+	if err = os.Rename(filepath.Join(dir, "location.csv"), filepath.Join(dir, "location.csv.gz")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error in basic function: %s", err)
+	}
+
+	if err = d.Validate(); err == nil {
+		t.Errorf("Validate(): no error thrown for corrupt compressed file")
+	}
+
+}
@@ -0,0 +1,226 @@
+package datadirectory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CSVW datatypes for data models service field types. Unknown types are
+// written as "string".
+var csvwDatatypes = map[string]string{
+	"integer":   "integer",
+	"bigint":    "integer",
+	"string":    "string",
+	"text":      "string",
+	"clob":      "string",
+	"date":      "date",
+	"datetime":  "datetime",
+	"timestamp": "datetime",
+	"time":      "time",
+	"decimal":   "decimal",
+	"numeric":   "decimal",
+	"number":    "double",
+	"float":     "double",
+	"boolean":   "boolean",
+}
+
+// csvwTableGroup is CSVW table group metadata. Metadata values shared by all
+// records are kept as Dublin Core common properties.
+type csvwTableGroup struct {
+	Context    string       `json:"@context"`
+	Creator    string       `json:"dc:creator,omitempty"`
+	ConformsTo string       `json:"dc:conformsTo,omitempty"`
+	Version    string       `json:"dc:hasVersion,omitempty"`
+	Tables     []*csvwTable `json:"tables"`
+}
+
+// csvwTable is CSVW table metadata. The checksum of the file is recorded as
+// its provenance.
+type csvwTable struct {
+	URL         string           `json:"url"`
+	Title       string           `json:"dc:title"`
+	SHA256      string           `json:"schema:sha256"`
+	GeneratedBy string           `json:"prov:wasGeneratedBy,omitempty"`
+	TableSchema *csvwTableSchema `json:"tableSchema,omitempty"`
+}
+
+// csvwTableSchema is a CSVW schema.
+type csvwTableSchema struct {
+	Columns []*csvwColumn `json:"columns"`
+}
+
+// csvwColumn is a CSVW column description. Datatype is either the name of a
+// built-in datatype or a derived datatype description.
+type csvwColumn struct {
+	Name     string      `json:"name"`
+	Titles   string      `json:"titles"`
+	Datatype interface{} `json:"datatype"`
+	Required bool        `json:"required,omitempty"`
+}
+
+// csvwDerivedDatatype is a CSVW datatype derived from a built-in datatype.
+type csvwDerivedDatatype struct {
+	Base      string `json:"base"`
+	MaxLength int    `json:"maxLength,omitempty"`
+}
+
+// WriteCSVWToFile writes CSVW table group metadata for the DataDirectory to
+// the csv-metadata.json file in its DirPath, the default location CSVW
+// processors look for. An existing csv-metadata.json will be overwritten.
+func (d *DataDirectory) WriteCSVWToFile() error {
+
+	var (
+		file *os.File
+		err  error
+	)
+
+	if file, err = os.Create(filepath.Join(d.DirPath, "csv-metadata.json")); err != nil {
+		return err
+	}
+
+	if err = d.WriteCSVW(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// WriteCSVW writes CSVW (W3C CSV on the Web) table group metadata for the
+// DataDirectory to the passed writer. Each record becomes a table with its
+// filename as the URL, its checksum as provenance, and columns and datatypes
+// from the data models service field definitions of its table. Columns are
+// ordered by the header row of each data file, if it exists, since CSVW
+// matches columns by position.
+func (d *DataDirectory) WriteCSVW(w io.Writer) error {
+
+	var (
+		group   *csvwTableGroup
+		encoder *json.Encoder
+	)
+
+	group = &csvwTableGroup{
+		Context: "http://www.w3.org/ns/csvw",
+		Creator: d.headerDefault("organization"),
+		Version: d.headerDefault("data-version"),
+		Tables:  make([]*csvwTable, 0),
+	}
+
+	if model := d.headerDefault("cdm"); model != "" {
+		group.ConformsTo = strings.TrimSpace(model + " " + d.headerDefault("cdm-version"))
+	}
+
+	for _, recordMap := range d.RecordMaps {
+
+		var (
+			table    *csvwTable
+			modelDef *modelTable
+			name     string
+			err      error
+		)
+
+		if name, err = archiveName(recordMap["filename"]); err != nil {
+			return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+		}
+
+		table = &csvwTable{
+			URL:         name,
+			Title:       recordMap["table"],
+			SHA256:      recordMap["checksum"],
+			GeneratedBy: recordMap["etl"],
+		}
+
+		if modelDef = d.modelTable(recordMap["cdm"], recordMap["cdm-version"], recordMap["table"]); modelDef != nil {
+
+			var (
+				fields []*modelField
+				titles []string
+			)
+
+			if fields, titles, err = d.orderFields(recordMap, modelDef); err != nil {
+				return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+			}
+
+			table.TableSchema = &csvwTableSchema{Columns: make([]*csvwColumn, 0)}
+
+			for i, field := range fields {
+
+				var datatype = csvwDatatypes[strings.ToLower(field.Type)]
+
+				if datatype == "" {
+					datatype = "string"
+				}
+
+				column := &csvwColumn{
+					Name:     field.Name,
+					Titles:   titles[i],
+					Datatype: datatype,
+					Required: field.Required,
+				}
+
+				if datatype == "string" && field.Length > 0 {
+					column.Datatype = &csvwDerivedDatatype{Base: datatype, MaxLength: field.Length}
+				}
+
+				table.TableSchema.Columns = append(table.TableSchema.Columns, column)
+			}
+		}
+
+		group.Tables = append(group.Tables, table)
+	}
+
+	encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(group)
+}
+
+// orderFields returns the model fields of a table in the column order of the
+// data file for a record, with the column headers as written in the file.
+// Columns not defined by the model are typed as strings. If the data file
+// does not exist, the model order and field names are used.
+func (d *DataDirectory) orderFields(recordMap map[string]string, modelDef *modelTable) ([]*modelField, []string, error) {
+
+	var (
+		header []string
+		fields []*modelField
+		byName map[string]*modelField
+		err    error
+	)
+
+	if header, err = d.readDataHeader(recordMap); errors.Is(err, fs.ErrNotExist) {
+
+		for _, field := range modelDef.Fields {
+			header = append(header, field.Name)
+		}
+
+		return modelDef.Fields, header, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	byName = make(map[string]*modelField)
+
+	for _, field := range modelDef.Fields {
+		byName[field.Name] = field
+	}
+
+	for _, column := range header {
+
+		field, ok := byName[strings.ToLower(column)]
+
+		if !ok {
+			field = &modelField{Name: column, Type: "string"}
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, header, nil
+}
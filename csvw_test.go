@@ -0,0 +1,105 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestWriteCSVW(t *testing.T) {
+
+	var (
+		cfg   *datadirectory.Config
+		d     *datadirectory.DataDirectory
+		b     bytes.Buffer
+		group map[string]interface{}
+		err   error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	if err = d.WriteCSVW(&b); err != nil {
+		t.Fatalf("WriteCSVW(): error in basic function: %s", err)
+	}
+
+	if err = json.Unmarshal(b.Bytes(), &group); err != nil {
+		t.Fatalf("WriteCSVW(): invalid JSON written: %s", err)
+	}
+
+	tables := group["tables"].([]interface{})
+
+	if len(tables) != 3 {
+		t.Fatalf("WriteCSVW(): expected number of tables (3) does not match actual number (%d)", len(tables))
+	}
+
+	table := tables[1].(map[string]interface{})
+
+	if table["url"] != "care_site.csv" {
+		t.Errorf("WriteCSVW(): expected table url ('care_site.csv') does not match actual url ('%v')", table["url"])
+	}
+
+	if table["tableSchema"] == nil {
+		t.Errorf("WriteCSVW(): table schema not written")
+	}
+
+}
+
+func TestWriteCSVWTitles(t *testing.T) {
+
+	var (
+		dir   string
+		data  []byte
+		d     *datadirectory.DataDirectory
+		b     bytes.Buffer
+		group map[string]interface{}
+		err   error
+	)
+
+	dir = copyTestData(t)
+
+	// Write the header of the care_site file in upper case.
+	if data, err = os.ReadFile(filepath.Join(dir, "care_site.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitN(string(data), "\n", 2)
+	data = []byte(strings.ToUpper(lines[0]) + "\n" + lines[1])
+
+	if err = os.WriteFile(filepath.Join(dir, "care_site.csv"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ = datadirectory.New(&datadirectory.Config{DataDirPath: dir})
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.WriteCSVW(&b); err != nil {
+		t.Fatalf("WriteCSVW(): error in basic function: %s", err)
+	}
+
+	if err = json.Unmarshal(b.Bytes(), &group); err != nil {
+		t.Fatalf("WriteCSVW(): invalid JSON written: %s", err)
+	}
+
+	table := group["tables"].([]interface{})[1].(map[string]interface{})
+	column := table["tableSchema"].(map[string]interface{})["columns"].([]interface{})[0].(map[string]interface{})
+
+	if column["name"] != "care_site_id" || column["titles"] != "CARE_SITE_ID" {
+		t.Errorf("WriteCSVW(): expected column 'care_site_id' titled 'CARE_SITE_ID', got '%v' titled '%v'", column["name"], column["titles"])
+	}
+}
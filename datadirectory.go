@@ -3,6 +3,8 @@ package datadirectory
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -24,6 +26,14 @@ var canonicalHeader = []string{
 	"data-version",
 }
 
+// Optional *ordered* header values, added to the header only when a record
+// needs them.
+var optionalHeader = []string{
+	"compression",
+	"row-count",
+	"size",
+}
+
 // Permitted metadata header values and whether or not they are required.
 var headerReq = map[string]bool{
 	"organization": true,
@@ -34,18 +44,44 @@ var headerReq = map[string]bool{
 	"table":        true,
 	"etl":          true,
 	"data-version": false,
+	"compression":  false,
+	"row-count":    false,
+	"size":         false,
+}
+
+// modelTable is a simplified version of a data models service table
+// definition. PrimaryKey lists the primary key fields and ForeignKeys are
+// those declared on the table.
+type modelTable struct {
+	Fields      []*modelField
+	PrimaryKey  []string
+	ForeignKeys []*ForeignKey
+}
+
+// modelField is a simplified version of a data models service field
+// definition.
+type modelField struct {
+	Name     string
+	Type     string
+	Length   int
+	Required bool
 }
 
 // Config holds all potential configuration arguments for a DataDirectory
 // object. Only the DataDirPath is required.
 type Config struct {
-	DataDirPath  string
-	DataVersion  string
-	Etl          string
-	Model        string
-	ModelVersion string
-	Service      string
-	Site         string
+	DataDirPath    string
+	DataVersion    string
+	Etl            string
+	ForeignKeys    []*ForeignKey
+	FS             fs.FS
+	Logger         *slog.Logger
+	MetadataFormat ManifestFormat
+	Model          string
+	ModelVersion   string
+	Progress       ProgressReporter
+	Service        string
+	Site           string
 }
 
 // DataDirectory represents a particular data directory and a set of metadata
@@ -59,8 +95,24 @@ type DataDirectory struct {
 	Etl          string
 	DirPath      string
 	FilePath     string
-	header       []string
-	service      string
+	// FS, if set, is the file system data files and the metadata file are
+	// read from, with paths relative to its root; files are written to
+	// DirPath.
+	FS fs.FS
+	// ForeignKeys are checked by ValidateReferences along with those of the
+	// data models service.
+	ForeignKeys []*ForeignKey
+	// KeyMemoryLimit is the number of primary key values held in memory per
+	// table by ValidatePrimaryKeys, 1000000 if not set.
+	KeyMemoryLimit int
+	// Logger receives structured events, such as each data file hashed;
+	// slog.Default() if not set.
+	Logger *slog.Logger
+	// Progress receives progress reports from ValidateContext and
+	// PopulateContext.
+	Progress ProgressReporter
+	header   []string
+	service  string
 	/* serviceModels is a simplified version of data models service information
 	   and should look like:
 	   {
@@ -73,6 +125,9 @@ type DataDirectory struct {
 	   }
 	*/
 	serviceModels map[string]map[string]sort.StringSlice
+	// serviceTables holds the table definitions for each model and version,
+	// keyed by table name.
+	serviceTables map[string]map[string]map[string]*modelTable
 }
 
 // New creates a new DataDirectory object from a Config object. Only the
@@ -84,6 +139,7 @@ func New(cfg *Config) (*DataDirectory, error) {
 		cModels *client.Models
 		mFound  bool
 		vFound  bool
+		format  ManifestFormat
 		d       *DataDirectory
 		err     error
 	)
@@ -93,6 +149,15 @@ func New(cfg *Config) (*DataDirectory, error) {
 		return nil, errors.New("the DataDirectory object requires Config.DataDirPath")
 	}
 
+	// Return error if metadata format not supported.
+	if format = cfg.MetadataFormat; format == "" {
+		format = CSVFormat
+	}
+
+	if _, err = manifestCodecFor(format); err != nil {
+		return nil, err
+	}
+
 	// Initialize with any passed metadata information, standardizing to
 	// lowercase where appropriate.
 	d = &DataDirectory{
@@ -103,10 +168,15 @@ func New(cfg *Config) (*DataDirectory, error) {
 		DataVersion:   strings.ToLower(cfg.DataVersion),
 		Etl:           cfg.Etl,
 		DirPath:       cfg.DataDirPath,
-		FilePath:      filepath.Join(cfg.DataDirPath, "metadata.csv"),
+		FilePath:      filepath.Join(cfg.DataDirPath, "metadata."+string(format)),
+		FS:            cfg.FS,
+		ForeignKeys:   cfg.ForeignKeys,
+		Logger:        cfg.Logger,
+		Progress:      cfg.Progress,
 		header:        canonicalHeader,
 		service:       cfg.Service,
 		serviceModels: make(map[string]map[string]sort.StringSlice),
+		serviceTables: make(map[string]map[string]map[string]*modelTable),
 	}
 
 	// Initialize data models service client.
@@ -136,6 +206,51 @@ func New(cfg *Config) (*DataDirectory, error) {
 
 		d.serviceModels[cModel.Name]["sorted"] = append(d.serviceModels[cModel.Name]["sorted"], cModel.Version)
 		d.serviceModels[cModel.Name][cModel.Version] = cModel.Tables.Names()
+
+		// Construct serviceTables map.
+		if d.serviceTables[cModel.Name] == nil {
+			d.serviceTables[cModel.Name] = make(map[string]map[string]*modelTable)
+		}
+
+		d.serviceTables[cModel.Name][cModel.Version] = make(map[string]*modelTable)
+
+		for _, cTable := range cModel.Tables.List() {
+
+			table := &modelTable{}
+
+			for _, cField := range cTable.Fields.List() {
+				table.Fields = append(table.Fields, &modelField{
+					Name:     cField.Name,
+					Type:     cField.Type,
+					Length:   cField.Length,
+					Required: cField.Required,
+				})
+			}
+
+			d.serviceTables[cModel.Name][cModel.Version][cTable.Name] = table
+		}
+
+		// Attach the primary and foreign keys to their tables.
+		if cModel.Schema == nil || cModel.Schema.Constraints == nil {
+			continue
+		}
+
+		for _, cKey := range cModel.Schema.Constraints.PrimaryKeys {
+			if table := d.serviceTables[cModel.Name][cModel.Version][cKey.Table]; table != nil {
+				table.PrimaryKey = cKey.Fields
+			}
+		}
+
+		for _, cKey := range cModel.Schema.Constraints.ForeignKeys {
+			if table := d.serviceTables[cModel.Name][cModel.Version][cKey.Table]; table != nil {
+				table.ForeignKeys = append(table.ForeignKeys, &ForeignKey{
+					Table:            cKey.Table,
+					Fields:           cKey.Fields,
+					ReferencesTable:  cKey.ReferencesTable,
+					ReferencesFields: cKey.ReferencesFields,
+				})
+			}
+		}
 	}
 
 	// Check that model and model version, if passed, exist in models retrieved
@@ -178,3 +293,21 @@ func New(cfg *Config) (*DataDirectory, error) {
 
 	return d, nil
 }
+
+// modelTable returns the data models service definition of a table, or nil if
+// it is unknown. An empty version refers to the latest model version.
+func (d *DataDirectory) modelTable(model string, version string, table string) *modelTable {
+
+	if version == "" {
+		versions := d.serviceModels[model]["sorted"]
+
+		if len(versions) == 0 {
+			return nil
+		}
+
+		versions.Sort()
+		version = versions[len(versions)-1]
+	}
+
+	return d.serviceTables[model][version][table]
+}
@@ -1,6 +1,8 @@
 package datadirectory_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/infomodels/datadirectory"
@@ -60,3 +62,35 @@ func TestUnknownModel(t *testing.T) {
 	}
 
 }
+
+// copyTestData copies the files in test_data into a temporary directory and
+// returns its path.
+func copyTestData(t *testing.T) string {
+
+	var (
+		dir   string
+		names []string
+		err   error
+	)
+
+	dir = t.TempDir()
+
+	if names, err = filepath.Glob(filepath.Join("test_data", "*")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+
+		var data []byte
+
+		if data, err = os.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, filepath.Base(name)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
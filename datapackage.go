@@ -0,0 +1,345 @@
+package datadirectory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Table Schema field types for data models service field types. Unknown
+// types are written as "any".
+var tableSchemaTypes = map[string]string{
+	"integer":   "integer",
+	"bigint":    "integer",
+	"string":    "string",
+	"text":      "string",
+	"clob":      "string",
+	"date":      "date",
+	"datetime":  "datetime",
+	"timestamp": "datetime",
+	"time":      "time",
+	"decimal":   "number",
+	"number":    "number",
+	"numeric":   "number",
+	"float":     "number",
+	"boolean":   "boolean",
+}
+
+// Media types of compressed data files, by compression. Uncompressed data
+// files are "text/csv".
+var compressionMediatypes = map[string]string{
+	"gzip":  "application/gzip",
+	"bzip2": "application/x-bzip2",
+	"zstd":  "application/zstd",
+}
+
+// dataPackage is a Frictionless Data Package descriptor. Metadata values
+// shared by all records are kept as custom package properties.
+type dataPackage struct {
+	Profile      string                 `json:"profile"`
+	Name         string                 `json:"name,omitempty"`
+	Organization string                 `json:"organization,omitempty"`
+	CDM          string                 `json:"cdm,omitempty"`
+	CDMVersion   string                 `json:"cdm-version,omitempty"`
+	ETL          string                 `json:"etl,omitempty"`
+	DataVersion  string                 `json:"data-version,omitempty"`
+	Resources    []*dataPackageResource `json:"resources"`
+}
+
+// dataPackageResource is a Tabular Data Resource with the metadata table as
+// a custom property.
+type dataPackageResource struct {
+	Name        string       `json:"name"`
+	Path        string       `json:"path"`
+	Profile     string       `json:"profile"`
+	Format      string       `json:"format"`
+	Mediatype   string       `json:"mediatype"`
+	Compression string       `json:"compression,omitempty"`
+	Encoding    string       `json:"encoding"`
+	Hash        string       `json:"hash"`
+	Table       string       `json:"table"`
+	Schema      *tableSchema `json:"schema,omitempty"`
+}
+
+// tableSchema is a Frictionless Table Schema.
+type tableSchema struct {
+	Fields []*tableSchemaField `json:"fields"`
+}
+
+// tableSchemaField is a Table Schema field descriptor.
+type tableSchemaField struct {
+	Name        string                  `json:"name"`
+	Type        string                  `json:"type"`
+	Constraints *tableSchemaConstraints `json:"constraints,omitempty"`
+}
+
+// tableSchemaConstraints holds Table Schema field constraints.
+type tableSchemaConstraints struct {
+	Required  bool `json:"required,omitempty"`
+	MaxLength int  `json:"maxLength,omitempty"`
+}
+
+// WriteDataPackageToFile writes a datapackage.json file describing the
+// DataDirectory to its DirPath. An existing datapackage.json will be
+// overwritten.
+func (d *DataDirectory) WriteDataPackageToFile() error {
+
+	var (
+		file *os.File
+		err  error
+	)
+
+	if file, err = os.Create(filepath.Join(d.DirPath, "datapackage.json")); err != nil {
+		return err
+	}
+
+	if err = d.WriteDataPackage(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// WriteDataPackage writes a Frictionless Data Package descriptor
+// (datapackage.json) for the DataDirectory to the passed writer. Each record
+// becomes a resource with its path, sha256 hash and table, and a Table Schema
+// built from the data models service field definitions of that table, in the
+// column order of the data file. Compressed data files are described by
+// their compression extension, such as "gz", and media type.
+func (d *DataDirectory) WriteDataPackage(w io.Writer) error {
+
+	var (
+		pkg     *dataPackage
+		names   map[string]int
+		encoder *json.Encoder
+	)
+
+	pkg = &dataPackage{
+		Profile:      "tabular-data-package",
+		Organization: d.headerDefault("organization"),
+		CDM:          d.headerDefault("cdm"),
+		CDMVersion:   d.headerDefault("cdm-version"),
+		ETL:          d.headerDefault("etl"),
+		DataVersion:  d.headerDefault("data-version"),
+		Resources:    make([]*dataPackageResource, 0),
+	}
+
+	pkg.Name = dataPackageName(strings.Join([]string{pkg.Organization, pkg.CDM, pkg.CDMVersion, pkg.DataVersion}, "-"))
+
+	names = make(map[string]int)
+
+	for _, recordMap := range d.RecordMaps {
+
+		var (
+			resource *dataPackageResource
+			table    *modelTable
+			name     string
+			err      error
+		)
+
+		if name, err = archiveName(recordMap["filename"]); err != nil {
+			return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+		}
+
+		resource = &dataPackageResource{
+			Path:      name,
+			Profile:   "tabular-data-resource",
+			Format:    "csv",
+			Mediatype: "text/csv",
+			Encoding:  "utf-8",
+			Hash:      "sha256:" + recordMap["checksum"],
+			Table:     recordMap["table"],
+		}
+
+		if compression := recordCompression(recordMap); compression != "" {
+
+			if err = checkCompression(compression); err != nil {
+				return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+			}
+
+			for ext, c := range compressionExts {
+				if c == compression {
+					resource.Compression = strings.TrimPrefix(ext, ".")
+				}
+			}
+
+			resource.Mediatype = compressionMediatypes[compression]
+		}
+
+		// Resource names must be unique, so number repeated file names.
+		name, _, _ = splitDataFileName(name)
+		name = dataPackageName(name)
+
+		if names[name]++; names[name] > 1 {
+			name = name + "-" + strconv.Itoa(names[name])
+		}
+
+		resource.Name = name
+
+		if table = d.modelTable(recordMap["cdm"], recordMap["cdm-version"], recordMap["table"]); table != nil {
+
+			var fields []*modelField
+
+			if fields, _, err = d.orderFields(recordMap, table); err != nil {
+				return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+			}
+
+			resource.Schema = &tableSchema{Fields: make([]*tableSchemaField, 0)}
+
+			for _, field := range fields {
+
+				schemaField := &tableSchemaField{
+					Name: field.Name,
+					Type: tableSchemaTypes[strings.ToLower(field.Type)],
+				}
+
+				if schemaField.Type == "" {
+					schemaField.Type = "any"
+				}
+
+				constraints := &tableSchemaConstraints{Required: field.Required}
+
+				if schemaField.Type == "string" {
+					constraints.MaxLength = field.Length
+				}
+
+				if *constraints != (tableSchemaConstraints{}) {
+					schemaField.Constraints = constraints
+				}
+
+				resource.Schema.Fields = append(resource.Schema.Fields, schemaField)
+			}
+		}
+
+		pkg.Resources = append(pkg.Resources, resource)
+	}
+
+	encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(pkg)
+}
+
+// dataPackageName lowercases s and replaces characters not permitted in Data
+// Package names with dashes.
+func dataPackageName(s string) string {
+
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.Trim(strings.ToLower(s), "-"))
+}
+
+// ReadDataPackageFromFile reads the datapackage.json file in the
+// DataDirectory's DirPath into the RecordMaps.
+func (d *DataDirectory) ReadDataPackageFromFile() error {
+
+	var (
+		file fs.File
+		err  error
+	)
+
+	if file, err = d.fsys().Open("datapackage.json"); err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return d.ReadDataPackage(file)
+}
+
+// ReadDataPackage reads a Frictionless Data Package descriptor from the
+// passed reader, appending a record to the RecordMaps for each resource.
+// Resources must have a single path and a sha256 hash. Resources without a
+// table property are mapped to a table by file name, as in
+// PopulateMetadataFromData. The compression of a resource is taken from its
+// file extension or, failing that, its compression property.
+func (d *DataDirectory) ReadDataPackage(r io.Reader) error {
+
+	var (
+		pkg *dataPackage
+		err error
+	)
+
+	pkg = &dataPackage{}
+
+	if err = json.NewDecoder(r).Decode(pkg); err != nil {
+		return fmt.Errorf("could not read data package: %s", err)
+	}
+
+	if len(d.header) == 0 {
+		d.header = canonicalHeader
+	}
+
+	for i, resource := range pkg.Resources {
+
+		var (
+			filename    string
+			compression string
+			recordMap   map[string]string
+			table       string
+		)
+
+		if !strings.HasPrefix(resource.Hash, "sha256:") {
+			return fmt.Errorf("resource %d '%s' hash is not sha256", i, resource.Name)
+		}
+
+		if filename, err = archiveName(resource.Path); err != nil {
+			return fmt.Errorf("resource %d '%s' %s", i, resource.Name, err)
+		}
+
+		if table = strings.ToLower(resource.Table); table == "" {
+			if table, err = d.tableForFile(path.Base(filename)); err != nil {
+				return err
+			}
+		}
+
+		if _, compression, _ = splitDataFileName(filename); compression == "" && resource.Compression != "" {
+			if compression = compressionExts["."+resource.Compression]; compression == "" {
+				return fmt.Errorf("resource %d '%s' compression '%s' not supported", i, resource.Name, resource.Compression)
+			}
+		}
+
+		if compression != "" {
+			d.addHeader("compression")
+		}
+
+		recordMap = make(map[string]string)
+
+		for _, val := range d.header {
+			switch val {
+			case "organization":
+				recordMap[val] = pkg.Organization
+			case "filename":
+				recordMap[val] = filepath.FromSlash(filename)
+			case "checksum":
+				recordMap[val] = strings.ToLower(strings.TrimPrefix(resource.Hash, "sha256:"))
+			case "cdm":
+				recordMap[val] = strings.ToLower(pkg.CDM)
+			case "cdm-version":
+				recordMap[val] = strings.ToLower(pkg.CDMVersion)
+			case "table":
+				recordMap[val] = table
+			case "etl":
+				recordMap[val] = pkg.ETL
+			case "data-version":
+				recordMap[val] = strings.ToLower(pkg.DataVersion)
+			case "compression":
+				recordMap[val] = compression
+			}
+		}
+
+		d.appendRecord(recordMap)
+	}
+
+	return nil
+}
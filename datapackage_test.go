@@ -0,0 +1,141 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestWriteDataPackage(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		pkg map[string]interface{}
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	if err = d.WriteDataPackage(&b); err != nil {
+		t.Fatalf("WriteDataPackage(): error in basic function: %s", err)
+	}
+
+	if err = json.Unmarshal(b.Bytes(), &pkg); err != nil {
+		t.Fatalf("WriteDataPackage(): invalid JSON written: %s", err)
+	}
+
+	resources := pkg["resources"].([]interface{})
+
+	if len(resources) != 3 {
+		t.Fatalf("WriteDataPackage(): expected number of resources (3) does not match actual number (%d)", len(resources))
+	}
+
+	resource := resources[0].(map[string]interface{})
+
+	if resource["hash"] != "sha256:eee663c6095229e6ed62aeb3e41cc49a714b6c74eaa363454aae7e4d7cc208bd" {
+		t.Errorf("WriteDataPackage(): unexpected resource hash (%v)", resource["hash"])
+	}
+
+	if resource["schema"] == nil {
+		t.Errorf("WriteDataPackage(): resource schema not written")
+	}
+
+}
+
+func TestDataPackageRoundTrip(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	if err = d.WriteDataPackage(&b); err != nil {
+		t.Fatalf("WriteDataPackage(): error in basic function: %s", err)
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadDataPackage(&b); err != nil {
+		t.Fatalf("ReadDataPackage(): error in basic function: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Fatalf("ReadDataPackage(): expected number of RecordMaps (3) does not match actual length (%d)", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after ReadDataPackage(): %s", err)
+	}
+
+}
+
+func TestDataPackageCompression(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		pkg map[string]interface{}
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.RecordMaps[0]["filename"] = "location.csv.gz"
+
+	if err = d.WriteDataPackage(&b); err != nil {
+		t.Fatalf("WriteDataPackage(): error in basic function: %s", err)
+	}
+
+	if err = json.Unmarshal(b.Bytes(), &pkg); err != nil {
+		t.Fatalf("WriteDataPackage(): invalid JSON written: %s", err)
+	}
+
+	resource := pkg["resources"].([]interface{})[0].(map[string]interface{})
+
+	if resource["compression"] != "gz" || resource["mediatype"] != "application/gzip" {
+		t.Errorf("WriteDataPackage(): expected gz compression and application/gzip media type, got (%v, %v)", resource["compression"], resource["mediatype"])
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadDataPackage(&b); err != nil {
+		t.Fatalf("ReadDataPackage(): error in basic function: %s", err)
+	}
+
+	if d.RecordMaps[0]["compression"] != "gzip" {
+		t.Errorf("ReadDataPackage(): expected compression ('gzip') does not match actual compression ('%s')", d.RecordMaps[0]["compression"])
+	}
+}
@@ -0,0 +1,241 @@
+package datadirectory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default maximum number of days dates are shifted by.
+const defaultMaxShiftDays = 365
+
+// Layouts of the date and datetime values shifted by ShiftDates, tried in
+// order. Time of day values are not shifted.
+var shiftTimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	time.RFC3339Nano,
+}
+
+// ShiftDates writes a copy of the data files listed in the metadata to a new
+// data directory at dirPath, which must not exist yet, with every date and
+// datetime field, as typed by the data models service, shifted by a number
+// of days between -maxDays and maxDays, but never zero. The number of days
+// is derived from the row's person_id with a keyed hash of the secret, so it
+// is the same for a person across tables and runs with the same secret. A
+// maxDays of zero or less defaults to 365. The year_of_birth,
+// month_of_birth and day_of_birth of a row are shifted together when all are
+// present. Rows with dates but no person_id cannot be shifted consistently:
+// they are dropped if dropUnlinked is set and are an error otherwise. The new
+// directory gets its own metadata.csv and a transforms.json file recording
+// the shift, but not the secret.
+func (d *DataDirectory) ShiftDates(dirPath string, secret []byte, maxDays int, dropUnlinked bool) (*DataDirectory, error) {
+
+	var record *TransformRecord
+
+	if len(secret) == 0 {
+		return nil, errors.New("date shifting requires a secret")
+	}
+
+	if maxDays <= 0 {
+		maxDays = defaultMaxShiftDays
+	}
+
+	record = &TransformRecord{
+		Transform: "date-shift",
+		KeyID:     keyID(secret),
+		Parameters: map[string]string{
+			"max-days":      strconv.Itoa(maxDays),
+			"drop-unlinked": strconv.FormatBool(dropUnlinked),
+		},
+	}
+
+	return d.transform(dirPath, func(table string, header []string) (func(row []string) (bool, error), error) {
+		return d.shiftRow(table, header, secret, maxDays, dropUnlinked, record)
+	}, record)
+}
+
+// shiftRow returns the function shifting the dates of a row of the passed
+// table and header, adding the shifted columns to the transform record.
+func (d *DataDirectory) shiftRow(table string, header []string, secret []byte, maxDays int, dropUnlinked bool, record *TransformRecord) (func(row []string) (bool, error), error) {
+
+	var (
+		modelDef  *modelTable
+		dateCols  []int
+		personCol = -1
+		birthCols []int
+		records   = d.RecordsByTable(table)
+	)
+
+	modelDef = d.modelTable(records[0].CDM, records[0].CDMVersion, table)
+
+	for i, column := range header {
+
+		column = strings.ToLower(column)
+
+		switch typ := tableSchemaTypes[modelFieldType(modelDef, column)]; {
+		case column == "person_id":
+			personCol = i
+		case typ == "date" || typ == "datetime":
+			dateCols = append(dateCols, i)
+			record.appendColumn(table, column)
+		}
+	}
+
+	if cols, err := fieldColumns(header, []string{"year_of_birth", "month_of_birth", "day_of_birth"}); err == nil && personCol >= 0 {
+		birthCols = cols
+		for _, i := range cols {
+			record.appendColumn(table, strings.ToLower(header[i]))
+		}
+	}
+
+	return func(row []string) (bool, error) {
+
+		var (
+			person string
+			days   int
+		)
+
+		if personCol >= 0 && personCol < len(row) {
+			person = row[personCol]
+		}
+
+		if person == "" {
+
+			for _, i := range dateCols {
+
+				if i >= len(row) || row[i] == "" {
+					continue
+				}
+
+				if dropUnlinked {
+					return false, nil
+				}
+
+				if personCol < 0 {
+					return false, fmt.Errorf("column '%s' has a date but the table has no person_id column", header[i])
+				}
+
+				return false, fmt.Errorf("column '%s' has a date but person_id is empty", header[i])
+			}
+
+			return true, nil
+		}
+
+		days = shiftDays(secret, person, maxDays)
+
+		for _, i := range dateCols {
+
+			var err error
+
+			if i >= len(row) || row[i] == "" {
+				continue
+			}
+
+			if row[i], err = shiftDate(row[i], days); err != nil {
+				return false, fmt.Errorf("column '%s' %s", header[i], err)
+			}
+		}
+
+		if birthCols != nil {
+			if err := shiftBirth(row, birthCols, days); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}, nil
+}
+
+// shiftDays returns the number of days to shift the dates of a person by,
+// between -maxDays and maxDays but never zero, derived from a keyed hash of
+// the person_id.
+func shiftDays(secret []byte, person string, maxDays int) int {
+
+	var (
+		mac  = hmac.New(sha256.New, secret)
+		n    uint64
+		days int
+	)
+
+	mac.Write([]byte("date-shift\x00" + person))
+	n = binary.BigEndian.Uint64(mac.Sum(nil))
+
+	days = int((n>>1)%uint64(maxDays)) + 1
+
+	if n&1 == 1 {
+		days = -days
+	}
+
+	return days
+}
+
+// shiftDate shifts a date or datetime value by the passed number of days,
+// keeping its layout, including the number of fractional second digits.
+func shiftDate(value string, days int) (string, error) {
+
+	for _, layout := range shiftTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.AddDate(0, 0, days).Format(fractionLayout(layout, value)), nil
+		}
+	}
+
+	return "", fmt.Errorf("value '%s' is not a date", value)
+}
+
+// fractionLayout returns the layout with the seconds followed by as many
+// fractional digits as the value has, so that formatting keeps them,
+// trailing zeros included.
+func fractionLayout(layout string, value string) string {
+
+	var digits int
+
+	if i := strings.LastIndex(value, "."); i >= 0 {
+		for j := i + 1; j < len(value) && value[j] >= '0' && value[j] <= '9'; j++ {
+			digits++
+		}
+	}
+
+	if digits == 0 {
+		return layout
+	}
+
+	layout = strings.Replace(layout, ".999999999", "", 1)
+
+	return strings.Replace(layout, "05", "05."+strings.Repeat("0", digits), 1)
+}
+
+// shiftBirth shifts the year, month and day of birth values at the passed
+// columns of a row, if all are present.
+func shiftBirth(row []string, columns []int, days int) error {
+
+	var parts [3]int
+
+	for i, column := range columns {
+
+		var err error
+
+		if column >= len(row) || row[column] == "" {
+			return nil
+		}
+
+		if parts[i], err = strconv.Atoi(row[column]); err != nil {
+			return fmt.Errorf("birth value '%s' is not a number", row[column])
+		}
+	}
+
+	birth := time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC).AddDate(0, 0, days)
+
+	row[columns[0]] = strconv.Itoa(birth.Year())
+	row[columns[1]] = strconv.Itoa(int(birth.Month()))
+	row[columns[2]] = strconv.Itoa(birth.Day())
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/infomodels/datadirectory"
+)
+
+// personTestData adds person and visit_occurrence files to a copy of the
+// test data, returning the DataDirectory for it.
+func personTestData(t *testing.T) *datadirectory.DataDirectory {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	dir = copyTestData(t)
+
+	if err = os.WriteFile(filepath.Join(dir, "person.csv"), []byte("person_id,birth_datetime,birth_date,care_site_id,provider_id,person_source_value\n\"1\",\"2010-04-05 08:30:00\",\"2010-04-05\",\"1\",\"25147\",\"MRN 1001\"\n\"2\",,\"2012-12-31\",\"2\",,\"MRN 1002\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "visit_occurrence.csv"), []byte("visit_occurrence_id,person_id,visit_start_date,visit_start_time,provider_id,care_site_id,visit_source_value\n\"10\",\"1\",\"2015-01-01\",\"2015-01-01T09:00:00Z\",\"25147\",\"1\",\"MRN 1001\"\n\"11\",\"2\",\"2016-02-29\",,,\"2\",\"MRN 1002\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Model = "pedsnet"
+	d.ModelVersion = "2.1.0"
+
+	for _, name := range []string{"person.csv", "visit_occurrence.csv"} {
+		if err = d.AddFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return d
+}
+
+// tableRows reads every row of a table.
+func tableRows(t *testing.T, d *datadirectory.DataDirectory, table string) []map[string]string {
+
+	var (
+		reader *datadirectory.TableReader
+		rows   []map[string]string
+		err    error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+
+	for reader.Next() {
+		rows = append(rows, reader.Row())
+	}
+
+	if err = reader.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
+
+func TestShiftDates(t *testing.T) {
+
+	var (
+		d          *datadirectory.DataDirectory
+		dst        *datadirectory.DataDirectory
+		dir        string
+		transforms []*datadirectory.TransformRecord
+		err        error
+	)
+
+	d = personTestData(t)
+	dir = filepath.Join(t.TempDir(), "shifted")
+
+	if dst, err = d.ShiftDates(dir, []byte("secret"), 30, false); err != nil {
+		t.Fatalf("ShiftDates(): error in basic function: %s", err)
+	}
+
+	if err = dst.Validate(); err != nil {
+		t.Errorf("ShiftDates(): shifted data directory does not validate: %s", err)
+	}
+
+	days := func(layout string, before string, after string) int {
+
+		b, _ := time.Parse(layout, before)
+		a, err := time.Parse(layout, after)
+
+		if err != nil {
+			t.Fatalf("ShiftDates(): shifted value '%s' does not keep layout '%s'", after, layout)
+		}
+
+		return int(a.Sub(b).Hours() / 24)
+	}
+
+	people := tableRows(t, dst, "person")
+	visits := tableRows(t, dst, "visit_occurrence")
+
+	offset := days("2006-01-02", "2010-04-05", people[0]["birth_date"])
+
+	if offset == 0 || offset < -30 || offset > 30 {
+		t.Errorf("ShiftDates(): offset %d out of range", offset)
+	}
+
+	if days("2006-01-02 15:04:05", "2010-04-05 08:30:00", people[0]["birth_datetime"]) != offset {
+		t.Errorf("ShiftDates(): birth_datetime not shifted by %d days", offset)
+	}
+
+	if days("2006-01-02", "2015-01-01", visits[0]["visit_start_date"]) != offset || days(time.RFC3339, "2015-01-01T09:00:00Z", visits[0]["visit_start_time"]) != offset {
+		t.Errorf("ShiftDates(): visit of person 1 not shifted by %d days", offset)
+	}
+
+	if days("2006-01-02", "2012-12-31", people[1]["birth_date"]) != days("2006-01-02", "2016-02-29", visits[1]["visit_start_date"]) {
+		t.Errorf("ShiftDates(): dates of person 2 not shifted consistently")
+	}
+
+	if people[0]["person_source_value"] != "MRN 1001" || people[1]["birth_datetime"] != "" {
+		t.Errorf("ShiftDates(): unexpected change to values that are not dates")
+	}
+
+	if transforms, err = dst.Transforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transforms) != 1 || transforms[0].Transform != "date-shift" || len(transforms[0].Columns["visit_occurrence"]) != 2 {
+		t.Errorf("ShiftDates(): unexpected transform record %+v", transforms)
+	}
+
+	// The same secret gives the same shift.
+	if dst, err = d.ShiftDates(filepath.Join(t.TempDir(), "again"), []byte("secret"), 30, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if tableRows(t, dst, "person")[0]["birth_date"] != people[0]["birth_date"] {
+		t.Errorf("ShiftDates(): shift not consistent across runs")
+	}
+}
+
+func TestShiftDatesUnlinked(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		dst *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	d = personTestData(t)
+
+	// A visit with a date but no person_id.
+	if err = os.WriteFile(filepath.Join(d.DirPath, "visit_occurrence.csv"), []byte("visit_occurrence_id,person_id,visit_start_date\n\"10\",\"1\",\"2015-01-01\"\n\"11\",,\"2016-02-29\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("visit_occurrence.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir = filepath.Join(t.TempDir(), "shifted")
+
+	if _, err = d.ShiftDates(dir, []byte("secret"), 30, false); err == nil {
+		t.Fatalf("ShiftDates(): no error thrown for date without person_id")
+	}
+
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("ShiftDates(): partly written directory not removed")
+	}
+
+	if dst, err = d.ShiftDates(dir, []byte("secret"), 30, true); err != nil {
+		t.Fatalf("ShiftDates(): error dropping unlinked rows: %s", err)
+	}
+
+	if visits := tableRows(t, dst, "visit_occurrence"); len(visits) != 1 || visits[0]["visit_occurrence_id"] != "10" {
+		t.Errorf("ShiftDates(): expected only visit 10 to be kept, got %v", visits)
+	}
+}
+
+func TestShiftDatesExtraColumn(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		dst *datadirectory.DataDirectory
+		err error
+	)
+
+	d = personTestData(t)
+
+	// A column that is not in the model.
+	if err = os.WriteFile(filepath.Join(d.DirPath, "person.csv"), []byte("person_id,birth_date,site_note\n\"1\",\"2010-04-05\",\"note\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("person.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst, err = d.ShiftDates(filepath.Join(t.TempDir(), "shifted"), []byte("secret"), 30, false); err != nil {
+		t.Fatalf("ShiftDates(): error for a column not in the model: %s", err)
+	}
+
+	if persons := tableRows(t, dst, "person"); len(persons) != 1 || persons[0]["site_note"] != "note" {
+		t.Errorf("ShiftDates(): expected the site_note column to be kept, got %v", persons)
+	}
+}
+
+func TestShiftDatesLayout(t *testing.T) {
+
+	var (
+		d      *datadirectory.DataDirectory
+		dst    *datadirectory.DataDirectory
+		b      bytes.Buffer
+		writer *gzip.Writer
+		record *datadirectory.Record
+		err    error
+	)
+
+	d = personTestData(t)
+
+	if err = d.RemoveFile("visit_occurrence.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A compressed visit file in a subdirectory, with fractional seconds.
+	writer = gzip.NewWriter(&b)
+	writer.Write([]byte("visit_occurrence_id,person_id,visit_start_time\n\"10\",\"1\",\"2015-01-01 09:00:00.500000\"\n"))
+	writer.Close()
+
+	if err = os.Mkdir(filepath.Join(d.DirPath, "visits"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(d.DirPath, "visits", "visit_occurrence.csv.gz"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.AddFile(filepath.Join("visits", "visit_occurrence.csv.gz"), "visit_occurrence"); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst, err = d.ShiftDates(filepath.Join(t.TempDir(), "shifted"), []byte("secret"), 30, false); err != nil {
+		t.Fatalf("ShiftDates(): error in basic function: %s", err)
+	}
+
+	if err = dst.Validate(); err != nil {
+		t.Errorf("ShiftDates(): shifted data directory does not validate: %s", err)
+	}
+
+	if record = dst.RecordByFilename(filepath.Join("visits", "visit_occurrence.csv.gz")); record == nil || record.Compression != "gzip" {
+		t.Fatalf("ShiftDates(): compressed file in subdirectory not kept: %v", dst.RecordMaps)
+	}
+
+	visit := tableRows(t, dst, "visit_occurrence")[0]["visit_start_time"]
+
+	if _, err = time.Parse("2006-01-02 15:04:05.000000", visit); err != nil || visit[len(visit)-7:] != ".500000" {
+		t.Errorf("ShiftDates(): fractional seconds not kept in '%s'", visit)
+	}
+}
@@ -0,0 +1,169 @@
+package datadirectory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Changeset describes the differences between the records of two
+// DataDirectory objects. Records are matched by filename.
+type Changeset struct {
+	Added   []*RecordChange `json:"added"`
+	Removed []*RecordChange `json:"removed"`
+	Changed []*RecordChange `json:"changed"`
+}
+
+// RecordChange describes a single added, removed or changed record. Fields is
+// only set for changed records.
+type RecordChange struct {
+	Filename string         `json:"filename"`
+	Table    string         `json:"table"`
+	Fields   []*FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange describes a metadata value that differs between two records.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Diff compares the records of DataDirectory a with those of DataDirectory b
+// and returns the changes needed to go from a to b. A checksum change means
+// the file content changed; changes to cdm-version, etl and the other
+// metadata columns are reported the same way.
+func Diff(a, b *DataDirectory) *Changeset {
+
+	var (
+		changeset *Changeset
+		fields    []string
+		aRecords  map[string]map[string]string
+		bRecords  map[string]map[string]string
+	)
+
+	// Compare all known metadata values.
+	fields = append(append(fields, canonicalHeader...), optionalHeader...)
+
+	changeset = &Changeset{
+		Added:   make([]*RecordChange, 0),
+		Removed: make([]*RecordChange, 0),
+		Changed: make([]*RecordChange, 0),
+	}
+
+	aRecords = recordsByFilename(a.RecordMaps)
+	bRecords = recordsByFilename(b.RecordMaps)
+
+	// Removed records, in the order of a.
+	for _, aRecord := range a.RecordMaps {
+		if _, ok := bRecords[filepath.Clean(aRecord["filename"])]; !ok {
+			changeset.Removed = append(changeset.Removed, &RecordChange{
+				Filename: aRecord["filename"],
+				Table:    aRecord["table"],
+			})
+		}
+	}
+
+	// Added and changed records, in the order of b.
+	for _, bRecord := range b.RecordMaps {
+
+		var (
+			aRecord map[string]string
+			change  *RecordChange
+			ok      bool
+		)
+
+		change = &RecordChange{
+			Filename: bRecord["filename"],
+			Table:    bRecord["table"],
+		}
+
+		if aRecord, ok = aRecords[filepath.Clean(bRecord["filename"])]; !ok {
+			changeset.Added = append(changeset.Added, change)
+			continue
+		}
+
+		for _, field := range fields {
+
+			if field == "filename" || aRecord[field] == bRecord[field] {
+				continue
+			}
+
+			change.Fields = append(change.Fields, &FieldChange{
+				Field: field,
+				Old:   aRecord[field],
+				New:   bRecord[field],
+			})
+		}
+
+		if len(change.Fields) > 0 {
+			changeset.Changed = append(changeset.Changed, change)
+		}
+	}
+
+	return changeset
+}
+
+// recordsByFilename maps each record to its cleaned filename.
+func recordsByFilename(recordMaps []map[string]string) map[string]map[string]string {
+
+	var records = make(map[string]map[string]string)
+
+	for _, recordMap := range recordMaps {
+		records[filepath.Clean(recordMap["filename"])] = recordMap
+	}
+
+	return records
+}
+
+// Empty reports whether the changeset contains no changes.
+func (c *Changeset) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// WriteJSON writes the changeset as indented JSON to the passed writer.
+func (c *Changeset) WriteJSON(w io.Writer) error {
+
+	var encoder *json.Encoder
+
+	encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(c)
+}
+
+// WriteText writes the changeset in a human-readable form to the passed
+// writer, one line per added (+), removed (-) or changed (~) record followed
+// by an indented line per changed value.
+func (c *Changeset) WriteText(w io.Writer) error {
+
+	var err error
+
+	for _, change := range c.Added {
+		if _, err = fmt.Fprintf(w, "+ %s (%s)\n", change.Filename, change.Table); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range c.Removed {
+		if _, err = fmt.Fprintf(w, "- %s (%s)\n", change.Filename, change.Table); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range c.Changed {
+
+		if _, err = fmt.Fprintf(w, "~ %s (%s)\n", change.Filename, change.Table); err != nil {
+			return err
+		}
+
+		for _, field := range change.Fields {
+			if _, err = fmt.Fprintf(w, "    %s: '%s' -> '%s'\n", field.Field, field.Old, field.New); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
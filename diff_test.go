@@ -0,0 +1,90 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestDiff(t *testing.T) {
+
+	var (
+		a         *datadirectory.DataDirectory
+		b         *datadirectory.DataDirectory
+		changeset *datadirectory.Changeset
+		err       error
+	)
+
+	const metadataA = "organization,filename,checksum,cdm,cdm-version,table,etl\n" +
+		"foo,location.csv,123,pedsnet,2.0.0,location,http://foo.org/etl\n" +
+		"foo,provider.csv,456,pedsnet,2.0.0,provider,http://foo.org/etl\n" +
+		"foo,care_site.csv,789,pedsnet,2.0.0,care_site,http://foo.org/etl\n"
+
+	const metadataB = "organization,filename,checksum,cdm,cdm-version,table,etl\n" +
+		"foo,location.csv,123,pedsnet,2.1.0,location,http://foo.org/etl\n" +
+		"foo,./care_site.csv,abc,pedsnet,2.1.0,care_site,http://foo.org/etl\n" +
+		"foo,person.csv,def,pedsnet,2.1.0,person,http://foo.org/etl\n"
+
+	a = &datadirectory.DataDirectory{}
+	b = &datadirectory.DataDirectory{}
+
+	if err = a.ReadMetadata(strings.NewReader(metadataA)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = b.ReadMetadata(strings.NewReader(metadataB)); err != nil {
+		t.Fatal(err)
+	}
+
+	changeset = datadirectory.Diff(a, b)
+
+	if len(changeset.Added) != 1 || changeset.Added[0].Filename != "person.csv" {
+		t.Errorf("Diff(): expected added record (person.csv) does not match actual added records (%v)", changeset.Added)
+	}
+
+	if len(changeset.Removed) != 1 || changeset.Removed[0].Filename != "provider.csv" {
+		t.Errorf("Diff(): expected removed record (provider.csv) does not match actual removed records (%v)", changeset.Removed)
+	}
+
+	if len(changeset.Changed) != 2 {
+		t.Fatalf("Diff(): expected number of changed records (2) does not match actual number (%d)", len(changeset.Changed))
+	}
+
+	if len(changeset.Changed[1].Fields) != 2 || changeset.Changed[1].Fields[0].Field != "checksum" {
+		t.Errorf("Diff(): expected checksum and cdm-version changes for care_site.csv, got %v", changeset.Changed[1].Fields)
+	}
+
+}
+
+func TestDiffWriteText(t *testing.T) {
+
+	var (
+		changeset *datadirectory.Changeset
+		b         bytes.Buffer
+		err       error
+	)
+
+	const text = "+ person.csv (person)\n~ location.csv (location)\n    checksum: '123' -> 'abc'\n"
+
+	changeset = &datadirectory.Changeset{
+		Added: []*datadirectory.RecordChange{
+			{Filename: "person.csv", Table: "person"},
+		},
+		Changed: []*datadirectory.RecordChange{
+			{Filename: "location.csv", Table: "location", Fields: []*datadirectory.FieldChange{
+				{Field: "checksum", Old: "123", New: "abc"},
+			}},
+		},
+	}
+
+	if err = changeset.WriteText(&b); err != nil {
+		t.Errorf("WriteText(): error in basic function: %s", err)
+	}
+
+	if b.String() != text {
+		t.Errorf("WriteText(): expected output ('%s') does not match actual output ('%s')", text, b.String())
+	}
+
+}
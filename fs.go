@@ -0,0 +1,110 @@
+package datadirectory
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// writeFileFS is a file system that files can be written to, such as an
+// ObjectFS.
+type writeFileFS interface {
+	fs.FS
+	WriteFile(name string, data []byte) error
+}
+
+// fsys returns the file system the data directory is read from: the FS
+// attribute if set, otherwise the DirPath directory on disk.
+func (d *DataDirectory) fsys() fs.FS {
+
+	if d.FS != nil {
+		return d.FS
+	}
+
+	if d.DirPath == "" {
+		return os.DirFS(".")
+	}
+
+	return os.DirFS(d.DirPath)
+}
+
+// fsPath converts a filename relative to the data directory into a path
+// within the data directory file system, rejecting names outside of it.
+func fsPath(filename string) (string, error) {
+
+	var (
+		name string
+		err  error
+	)
+
+	if name, err = archiveName(filename); err != nil {
+		return "", err
+	}
+
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: filename, Err: fs.ErrInvalid}
+	}
+
+	return name, nil
+}
+
+// openFile opens a file by its filename relative to the data directory.
+func (d *DataDirectory) openFile(filename string) (fs.File, error) {
+
+	var (
+		name string
+		err  error
+	)
+
+	if name, err = fsPath(filename); err != nil {
+		return nil, err
+	}
+
+	return d.fsys().Open(name)
+}
+
+// metadataPath returns the path of the metadata file within the data
+// directory file system. ok is false if the metadata file is outside the
+// data directory, in which case it is accessed on disk at FilePath.
+func (d *DataDirectory) metadataPath() (name string, ok bool) {
+
+	var (
+		dirPath = d.DirPath
+		relPath string
+		err     error
+	)
+
+	if dirPath == "" {
+		dirPath = "."
+	}
+
+	if relPath, err = filepath.Rel(dirPath, d.FilePath); err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return path.Clean(filepath.ToSlash(relPath)), true
+}
+
+// openMetadataFile opens the metadata file, through the data directory file
+// system if it is within the data directory.
+func (d *DataDirectory) openMetadataFile() (fs.File, error) {
+
+	if name, ok := d.metadataPath(); ok {
+		return d.fsys().Open(name)
+	}
+
+	return os.Open(d.FilePath)
+}
+
+// statMetadataFile returns the file info of the metadata file, through the
+// data directory file system if it is within the data directory.
+func (d *DataDirectory) statMetadataFile() (fs.FileInfo, error) {
+
+	if name, ok := d.metadataPath(); ok {
+		return fs.Stat(d.fsys(), name)
+	}
+
+	return os.Stat(d.FilePath)
+}
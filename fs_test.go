@@ -0,0 +1,151 @@
+package datadirectory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/infomodels/datadirectory"
+)
+
+// mapTestData returns the files in test_data as an in-memory file system.
+func mapTestData(t *testing.T) fstest.MapFS {
+
+	var (
+		fsys  fstest.MapFS
+		names []string
+		data  []byte
+		err   error
+	)
+
+	fsys = make(fstest.MapFS)
+
+	if names, err = filepath.Glob(filepath.Join("test_data", "*")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+
+		if data, err = os.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+
+		fsys[filepath.Base(name)] = &fstest.MapFile{Data: data, Mode: 0644}
+	}
+
+	return fsys
+}
+
+func TestFS(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		reader *datadirectory.TableReader
+		rows   int
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: t.TempDir(),
+		FS:          mapTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error reading from FS: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Errorf("ReadMetadataFromFile(): expected 3 records from FS, got %d", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error validating from FS: %s", err)
+	}
+
+	if reader, err = d.OpenTable("provider"); err != nil {
+		t.Fatalf("OpenTable(): error opening from FS: %s", err)
+	}
+
+	defer reader.Close()
+
+	for reader.Next() {
+		rows++
+	}
+
+	if err = reader.Err(); err != nil {
+		t.Errorf("OpenTable(): error reading from FS: %s", err)
+	}
+
+	if rows == 0 {
+		t.Errorf("OpenTable(): no rows read from FS")
+	}
+}
+
+func TestFSPopulate(t *testing.T) {
+
+	var (
+		cfg  *datadirectory.Config
+		d    *datadirectory.DataDirectory
+		fsys fstest.MapFS
+		err  error
+	)
+
+	fsys = mapTestData(t)
+	delete(fsys, "metadata.csv")
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+		FS:           fsys,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error walking FS: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Errorf("PopulateMetadataFromData(): expected 3 records from FS, got %d", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error validating populated FS metadata: %s", err)
+	}
+}
+
+func TestFSChecksumMismatch(t *testing.T) {
+
+	var (
+		cfg  *datadirectory.Config
+		d    *datadirectory.DataDirectory
+		fsys fstest.MapFS
+		err  error
+	)
+
+	fsys = mapTestData(t)
+	fsys["provider.csv"] = &fstest.MapFile{Data: []byte("provider_id\n1\n"), Mode: 0644}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: t.TempDir(),
+		FS:          fsys,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Validate(); err == nil {
+		t.Errorf("Validate(): no error thrown for modified file in FS")
+	}
+}
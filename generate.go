@@ -0,0 +1,383 @@
+package datadirectory
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range of the random dates of generated rows.
+var (
+	generateMinDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	generateMaxDate = time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+)
+
+// Letters of generated string values.
+const generateLetters = "abcdefghijklmnopqrstuvwxyz"
+
+// fieldRef is a field of a table.
+type fieldRef struct {
+	table string
+	field string
+}
+
+// generator holds the state of a Generate: the key fields of each table,
+// whose values are derived from the row number, the foreign keys of each
+// table and the field each foreign key field references.
+type generator struct {
+	d          *DataDirectory
+	rows       int
+	rand       *rand.Rand
+	keyFields  map[fieldRef]bool
+	keys       map[string][]*ForeignKey
+	references map[fieldRef]fieldRef
+}
+
+// keyRef is the referenced field and row of a foreign key field in a
+// generated row. A negative row leaves the field empty.
+type keyRef struct {
+	field fieldRef
+	row   int
+}
+
+// Generate writes a synthetic data directory to DirPath, creating it if
+// needed: a data file with rows random rows for every table of the
+// DataDirectory model and version in the data models service, followed by
+// the metadata file. Every field of a table is a column, with values valid
+// for the field type, and required fields are never empty. Primary keys are
+// unique and foreign keys, from the data models service and the
+// DataDirectory ForeignKeys, reference existing rows. The same seed
+// generates the same data. If the DataDirectory Site or Etl is not set,
+// "synthetic" is used.
+func (d *DataDirectory) Generate(rows int, seed int64) error {
+
+	var (
+		g      *generator
+		tables []string
+		err    error
+	)
+
+	if rows <= 0 {
+		return errors.New("the number of rows to generate must be positive")
+	}
+
+	if d.Model == "" {
+		return errors.New("generating a data directory requires a model")
+	}
+
+	if tables = d.serviceModels[d.Model][d.ModelVersion]; len(tables) == 0 {
+		return fmt.Errorf("model '%s' version '%s' not found in data models service", d.Model, d.ModelVersion)
+	}
+
+	if d.Site == "" {
+		d.Site = "synthetic"
+	}
+
+	if d.Etl == "" {
+		d.Etl = "synthetic"
+	}
+
+	if err = os.MkdirAll(d.DirPath, 0755); err != nil {
+		return err
+	}
+
+	g = &generator{
+		d:          d,
+		rows:       rows,
+		rand:       rand.New(rand.NewSource(seed)),
+		keyFields:  make(map[fieldRef]bool),
+		keys:       make(map[string][]*ForeignKey),
+		references: make(map[fieldRef]fieldRef),
+	}
+
+	if err = g.findKeys(tables); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+
+		start := time.Now()
+
+		if err = g.generateTable(table); err != nil {
+			return err
+		}
+
+		d.logger().Info("generated data file", "table", table, "rows", rows, "duration", time.Since(start))
+	}
+
+	return d.WriteMetadataToFile()
+}
+
+// findKeys finds the primary key fields, the fields referenced by foreign
+// keys, the foreign keys and the references of the foreign key fields of the
+// passed tables. The foreign keys of a table are ordered by decreasing
+// number of fields, so a field in several keys takes its row from the
+// composite one. Key and foreign key fields too short for the values of
+// every row are an error.
+func (g *generator) findKeys(tables []string) error {
+
+	var keys []*ForeignKey
+
+	for _, table := range tables {
+
+		modelDef := g.d.modelTable(g.d.Model, g.d.ModelVersion, table)
+
+		for _, field := range modelDef.PrimaryKey {
+			g.keyFields[fieldRef{table, strings.ToLower(field)}] = true
+		}
+
+		keys = append(keys, modelDef.ForeignKeys...)
+	}
+
+	keys = append(keys, g.d.ForeignKeys...)
+
+	for _, key := range keys {
+
+		// Skip references to tables that are not generated.
+		if g.d.modelTable(g.d.Model, g.d.ModelVersion, key.ReferencesTable) == nil {
+			continue
+		}
+
+		for i, field := range key.Fields {
+
+			ref := fieldRef{key.ReferencesTable, strings.ToLower(key.ReferencesFields[i])}
+
+			g.keyFields[ref] = true
+			g.references[fieldRef{key.Table, strings.ToLower(field)}] = ref
+		}
+
+		g.keys[key.Table] = append(g.keys[key.Table], key)
+	}
+
+	for _, tableKeys := range g.keys {
+		sort.SliceStable(tableKeys, func(i, j int) bool {
+			return len(tableKeys[i].Fields) > len(tableKeys[j].Fields)
+		})
+	}
+
+	for _, table := range tables {
+		for _, field := range g.d.modelTable(g.d.Model, g.d.ModelVersion, table).Fields {
+
+			var (
+				name = fieldRef{table, strings.ToLower(field.Name)}
+				typ  = tableSchemaTypes[strings.ToLower(field.Type)]
+			)
+
+			if ref, ok := g.references[name]; ok {
+				typ = tableSchemaTypes[modelFieldType(g.d.modelTable(g.d.Model, g.d.ModelVersion, ref.table), ref.field)]
+			} else if !g.keyFields[name] {
+				continue
+			}
+
+			// The value of the last row is the longest.
+			if value := keyFieldValue(typ, g.rows-1); field.Length > 0 && len(value) > field.Length {
+				return fmt.Errorf("column '%s.%s' length %d is too short for %d unique values", table, name.field, field.Length, g.rows)
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateTable writes a data file for a table.
+func (g *generator) generateTable(table string) error {
+
+	var (
+		modelDef = g.d.modelTable(g.d.Model, g.d.ModelVersion, table)
+		writer   *TableFileWriter
+		csvw     *csv.Writer
+		header   []string
+		required map[string]bool
+		row      []string
+		err      error
+	)
+
+	if writer, err = g.d.CreateTableFile(table); err != nil {
+		return err
+	}
+
+	required = make(map[string]bool)
+
+	for _, field := range modelDef.Fields {
+		header = append(header, field.Name)
+		required[strings.ToLower(field.Name)] = field.Required
+	}
+
+	writer.CheckHeader = true
+	csvw = csv.NewWriter(writer)
+	csvw.Write(header)
+
+	row = make([]string, len(modelDef.Fields))
+
+	for i := 0; i < g.rows; i++ {
+
+		refs := g.keyRefs(table, required, i)
+
+		for j, field := range modelDef.Fields {
+			row[j] = g.value(table, field, i, refs)
+		}
+
+		csvw.Write(row)
+	}
+
+	csvw.Flush()
+
+	if err = csvw.Error(); err != nil {
+		writer.Abort()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// keyRefs returns the referenced field and row of each foreign key field of
+// row i of a table, keyed by field name. One row is chosen for each foreign
+// key, so the fields of a composite key reference the same row. A key with a
+// key field of its own table, whose value is derived from the row number,
+// references the same row; otherwise a key references an earlier row of its
+// own table or a random row of another. Keys without required fields are
+// empty a fifth of the time.
+func (g *generator) keyRefs(table string, required map[string]bool, i int) map[string]keyRef {
+
+	var refs = make(map[string]keyRef)
+
+	for _, key := range g.keys[table] {
+
+		var (
+			anchored    bool
+			keyRequired bool
+			j           int
+		)
+
+		for _, field := range key.Fields {
+			anchored = anchored || g.keyFields[fieldRef{table, strings.ToLower(field)}]
+			keyRequired = keyRequired || required[strings.ToLower(field)]
+		}
+
+		switch {
+		case anchored:
+			j = i
+		case key.ReferencesTable == table:
+			if i == 0 && !keyRequired {
+				j = -1
+			} else if i > 0 {
+				j = g.rand.Intn(i)
+			}
+		default:
+			if !keyRequired && g.rand.Intn(5) == 0 {
+				j = -1
+			} else {
+				j = g.rand.Intn(g.rows)
+			}
+		}
+
+		// Fields of several keys keep the row of the first.
+		for k, field := range key.Fields {
+			if _, ok := refs[strings.ToLower(field)]; !ok {
+				refs[strings.ToLower(field)] = keyRef{fieldRef{key.ReferencesTable, strings.ToLower(key.ReferencesFields[k])}, j}
+			}
+		}
+	}
+
+	return refs
+}
+
+// value returns the value of a field for row i of a table. Key fields are
+// derived from the row number, so they are unique and can be referenced by
+// row number alone, and foreign key fields from the row they reference in
+// refs. Fields that are not required are empty a fifth of the time.
+func (g *generator) value(table string, field *modelField, i int, refs map[string]keyRef) string {
+
+	var (
+		name = fieldRef{table, strings.ToLower(field.Name)}
+		typ  = tableSchemaTypes[strings.ToLower(field.Type)]
+	)
+
+	if ref, ok := refs[name.field]; ok {
+
+		if ref.row < 0 {
+			return ""
+		}
+
+		refTable := g.d.modelTable(g.d.Model, g.d.ModelVersion, ref.field.table)
+
+		return keyFieldValue(tableSchemaTypes[modelFieldType(refTable, ref.field.field)], ref.row)
+	}
+
+	if g.keyFields[name] {
+		return keyFieldValue(typ, i)
+	}
+
+	if !field.Required && g.rand.Intn(5) == 0 {
+		return ""
+	}
+
+	switch typ {
+	case "integer":
+		return strconv.Itoa(g.rand.Intn(1000000))
+	case "number":
+		return strconv.FormatFloat(float64(g.rand.Intn(1000000))/100, 'f', 2, 64)
+	case "boolean":
+		return strconv.FormatBool(g.rand.Intn(2) == 1)
+	case "date":
+		return g.randomTime().Format("2006-01-02")
+	case "datetime":
+		return g.randomTime().Format("2006-01-02 15:04:05")
+	case "time":
+		return g.randomTime().Format("15:04:05")
+	}
+
+	return g.randomString(field.Length)
+}
+
+// keyFieldValue returns the value of a key field of the passed type for row
+// i: the row number for numbers and strings, and a date or datetime derived
+// from it otherwise.
+func keyFieldValue(typ string, i int) string {
+
+	switch typ {
+	case "date":
+		return generateMinDate.AddDate(0, 0, i).Format("2006-01-02")
+	case "datetime":
+		return generateMinDate.Add(time.Duration(i) * time.Second).Format("2006-01-02 15:04:05")
+	}
+
+	return strconv.Itoa(i + 1)
+}
+
+// randomTime returns a random time between generateMinDate and
+// generateMaxDate, to the second.
+func (g *generator) randomTime() time.Time {
+
+	var seconds = int64(generateMaxDate.Sub(generateMinDate) / time.Second)
+
+	return generateMinDate.Add(time.Duration(g.rand.Int63n(seconds)) * time.Second)
+}
+
+// randomString returns a random string of letters, at most length long if
+// length is positive.
+func (g *generator) randomString(length int) string {
+
+	var (
+		n   = 4 + g.rand.Intn(9)
+		buf []byte
+	)
+
+	if length > 0 && n > length {
+		n = length
+	}
+
+	buf = make([]byte, n)
+
+	for i := range buf {
+		buf[i] = generateLetters[g.rand.Intn(len(generateLetters))]
+	}
+
+	return string(buf)
+}
@@ -0,0 +1,116 @@
+package datadirectory_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestGenerate(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		read       *datadirectory.DataDirectory
+		keys       []*datadirectory.PrimaryKeyViolation
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  filepath.Join(t.TempDir(), "synthetic"),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+	}
+
+	if d, err = datadirectory.New(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Generate(20, 1); err != nil {
+		t.Fatalf("Generate(): error in basic function: %s", err)
+	}
+
+	read, _ = datadirectory.New(&datadirectory.Config{DataDirPath: cfg.DataDirPath})
+
+	if err = read.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("Generate(): metadata could not be read: %s", err)
+	}
+
+	if len(read.Tables()) != len(d.Tables()) || len(read.Tables()) == 0 {
+		t.Errorf("Generate(): expected %d tables in metadata, got %d", len(d.Tables()), len(read.Tables()))
+	}
+
+	if err = read.Validate(); err != nil {
+		t.Errorf("Generate(): generated data directory does not validate: %s", err)
+	}
+
+	if keys, err = read.ValidatePrimaryKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 0 {
+		t.Errorf("Generate(): expected no primary key violations, got %d", len(keys))
+	}
+
+	if violations, err = read.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, violation := range violations {
+		t.Errorf("Generate(): orphan references from '%s' to '%s'", violation.ForeignKey.Table, violation.ForeignKey.ReferencesTable)
+	}
+
+	if rows := tableRows(t, read, "person"); len(rows) != 20 {
+		t.Errorf("Generate(): expected 20 person rows, got %d", len(rows))
+	}
+
+	if err = d.Generate(0, 1); err == nil {
+		t.Errorf("Generate(): expected error for zero rows")
+	}
+}
+
+func TestGenerateCompositeKey(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		read       *datadirectory.DataDirectory
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	// A person references a provider and its care site together. The
+	// provider care_site_id is itself a foreign key to care_site.
+	cfg = &datadirectory.Config{
+		DataDirPath:  filepath.Join(t.TempDir(), "synthetic"),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		ForeignKeys: []*datadirectory.ForeignKey{
+			{Table: "person", Fields: []string{"provider_id", "care_site_id"}, ReferencesTable: "provider", ReferencesFields: []string{"provider_id", "care_site_id"}},
+		},
+	}
+
+	if d, err = datadirectory.New(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Generate(50, 3); err != nil {
+		t.Fatalf("Generate(): error in basic function: %s", err)
+	}
+
+	read, _ = datadirectory.New(&datadirectory.Config{DataDirPath: cfg.DataDirPath, ForeignKeys: cfg.ForeignKeys})
+
+	if err = read.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if violations, err = read.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, violation := range violations {
+		t.Errorf("Generate(): %d orphan references from '%s' %v to '%s'", violation.Count, violation.ForeignKey.Table, violation.ForeignKey.Fields, violation.ForeignKey.ReferencesTable)
+	}
+}
@@ -0,0 +1,17 @@
+package datadirectory
+
+import (
+	"log/slog"
+)
+
+// logger returns the logger for DataDirectory events: the Logger attribute if
+// set, otherwise slog.Default(), which writes through the log package unless
+// it has been replaced with slog.SetDefault.
+func (d *DataDirectory) logger() *slog.Logger {
+
+	if d.Logger != nil {
+		return d.Logger
+	}
+
+	return slog.Default()
+}
@@ -0,0 +1,71 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestLogger(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		buf    bytes.Buffer
+		events []map[string]interface{}
+		dec    *json.Decoder
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec = json.NewDecoder(&buf)
+
+	for dec.More() {
+
+		var event map[string]interface{}
+
+		if err = dec.Decode(&event); err != nil {
+			t.Fatalf("Validate(): invalid log output: %s", err)
+		}
+
+		events = append(events, event)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Validate(): expected 3 log events, got %d", len(events))
+	}
+
+	for _, event := range events {
+
+		if event["msg"] != "validated checksum" {
+			t.Errorf("Validate(): unexpected log message '%v'", event["msg"])
+		}
+
+		for _, key := range []string{"file", "table", "bytes", "duration"} {
+			if _, ok := event[key]; !ok {
+				t.Errorf("Validate(): log event missing '%s': %v", key, event)
+			}
+		}
+	}
+
+	if events[0]["file"] != "location.csv" || events[0]["table"] != "location" {
+		t.Errorf("Validate(): unexpected first log event %v", events[0])
+	}
+}
@@ -0,0 +1,365 @@
+package datadirectory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFormat is the encoding of a metadata file.
+type ManifestFormat string
+
+// Supported metadata file formats. CSV is the default.
+const (
+	CSVFormat  ManifestFormat = "csv"
+	JSONFormat ManifestFormat = "json"
+	YAMLFormat ManifestFormat = "yaml"
+)
+
+// manifestCodec decodes and encodes a metadata header and rows of values in
+// header order.
+type manifestCodec interface {
+	decode(r io.Reader) (header []string, rows [][]string, err error)
+	encode(w io.Writer, header []string, rows [][]string) error
+}
+
+// manifestCodecFor returns the codec for the passed format.
+func manifestCodecFor(format ManifestFormat) (manifestCodec, error) {
+
+	switch format {
+	case CSVFormat, "":
+		return csvCodec{}, nil
+	case JSONFormat:
+		return jsonCodec{}, nil
+	case YAMLFormat:
+		return yamlCodec{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported metadata format '%s'", format)
+}
+
+// manifestFormatFromPath returns the metadata format for a file path based
+// on its extension, defaulting to CSVFormat.
+func manifestFormatFromPath(path string) ManifestFormat {
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSONFormat
+	case ".yaml", ".yml":
+		return YAMLFormat
+	}
+
+	return CSVFormat
+}
+
+// csvCodec reads and writes metadata.csv files: a header row followed by one
+// row per record, with every value quoted on output.
+type csvCodec struct{}
+
+func (csvCodec) decode(r io.Reader) ([]string, [][]string, error) {
+
+	var (
+		csvReader *csv.Reader
+		header    []string
+		rows      [][]string
+		err       error
+	)
+
+	// Create a strict csv.Reader
+	csvReader = csv.NewReader(r)
+	csvReader.LazyQuotes = false
+	csvReader.TrimLeadingSpace = false
+
+	if header, err = csvReader.Read(); err != nil {
+		return nil, nil, err
+	}
+
+	if rows, err = csvReader.ReadAll(); err != nil {
+		return nil, nil, err
+	}
+
+	return header, rows, nil
+}
+
+func (csvCodec) encode(w io.Writer, header []string, rows [][]string) error {
+
+	var err error
+
+	// Write metadata header.
+	if err = writeQuotedRow(w, header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err = writeQuotedRow(w, row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeQuotedRow writes a csv row with every value quoted and the quotes in
+// values doubled, as csv.Writer does for the values it quotes. csv.Writer
+// itself cannot be used because it only quotes values that need it.
+func writeQuotedRow(w io.Writer, row []string) error {
+
+	var values = make([]string, len(row))
+
+	for i, value := range row {
+		values[i] = `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+
+	_, err := io.WriteString(w, strings.Join(values, ",")+"\n")
+
+	return err
+}
+
+// jsonCodec reads and writes metadata as a JSON array with one object per
+// record. Object keys keep the header order.
+type jsonCodec struct{}
+
+func (jsonCodec) decode(r io.Reader) ([]string, [][]string, error) {
+
+	var (
+		decoder *json.Decoder
+		records [][][2]string
+		err     error
+	)
+
+	decoder = json.NewDecoder(r)
+
+	if err = expectDelim(decoder, '['); err != nil {
+		return nil, nil, err
+	}
+
+	for decoder.More() {
+
+		var record [][2]string
+
+		if err = expectDelim(decoder, '{'); err != nil {
+			return nil, nil, err
+		}
+
+		for decoder.More() {
+
+			var (
+				key   json.Token
+				value interface{}
+			)
+
+			if key, err = decoder.Token(); err != nil {
+				return nil, nil, err
+			}
+
+			if err = decoder.Decode(&value); err != nil {
+				return nil, nil, err
+			}
+
+			record = append(record, [2]string{key.(string), manifestString(value)})
+		}
+
+		if err = expectDelim(decoder, '}'); err != nil {
+			return nil, nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	if err = expectDelim(decoder, ']'); err != nil {
+		return nil, nil, err
+	}
+
+	header, rows := manifestRows(records)
+
+	return header, rows, nil
+}
+
+func (jsonCodec) encode(w io.Writer, header []string, rows [][]string) error {
+
+	var b bytes.Buffer
+
+	b.WriteString("[")
+
+	for i, row := range rows {
+
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		b.WriteString("\n  {")
+
+		for j, val := range row {
+
+			key, _ := json.Marshal(header[j])
+			value, _ := json.Marshal(val)
+
+			if j > 0 {
+				b.WriteString(",")
+			}
+
+			fmt.Fprintf(&b, "\n    %s: %s", key, value)
+		}
+
+		b.WriteString("\n  }")
+	}
+
+	if len(rows) > 0 {
+		b.WriteString("\n")
+	}
+
+	b.WriteString("]\n")
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
+
+// expectDelim reads the next JSON token, returning an error if it is not the
+// passed delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+
+	var (
+		token json.Token
+		err   error
+	)
+
+	if token, err = decoder.Token(); err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("invalid metadata: expected '%s', found '%v'", delim, token)
+	}
+
+	return nil
+}
+
+// yamlCodec reads and writes metadata as a YAML sequence with one mapping
+// per record. Mapping keys keep the header order.
+type yamlCodec struct{}
+
+func (yamlCodec) decode(r io.Reader) ([]string, [][]string, error) {
+
+	var (
+		data     []byte
+		mappings []yaml.MapSlice
+		records  [][][2]string
+		err      error
+	)
+
+	if data, err = io.ReadAll(r); err != nil {
+		return nil, nil, err
+	}
+
+	if err = yaml.Unmarshal(data, &mappings); err != nil {
+		return nil, nil, err
+	}
+
+	for _, mapping := range mappings {
+
+		var record [][2]string
+
+		for _, item := range mapping {
+			record = append(record, [2]string{manifestString(item.Key), manifestString(item.Value)})
+		}
+
+		records = append(records, record)
+	}
+
+	header, rows := manifestRows(records)
+
+	return header, rows, nil
+}
+
+func (yamlCodec) encode(w io.Writer, header []string, rows [][]string) error {
+
+	var (
+		mappings []yaml.MapSlice
+		data     []byte
+		err      error
+	)
+
+	mappings = make([]yaml.MapSlice, 0)
+
+	for _, row := range rows {
+
+		var mapping yaml.MapSlice
+
+		for i, val := range row {
+			mapping = append(mapping, yaml.MapItem{Key: header[i], Value: val})
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	if data, err = yaml.Marshal(mappings); err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// manifestRows converts records of key and value pairs into a header, in
+// order of first appearance, and rows of values in header order. Missing
+// values are empty. Without any records, the canonical header is returned.
+func manifestRows(records [][][2]string) ([]string, [][]string) {
+
+	var (
+		header  []string
+		indexes map[string]int
+		rows    [][]string
+	)
+
+	if len(records) == 0 {
+		return append([]string(nil), canonicalHeader...), nil
+	}
+
+	indexes = make(map[string]int)
+
+	for _, record := range records {
+		for _, pair := range record {
+			if _, ok := indexes[pair[0]]; !ok {
+				indexes[pair[0]] = len(header)
+				header = append(header, pair[0])
+			}
+		}
+	}
+
+	for _, record := range records {
+
+		row := make([]string, len(header))
+
+		for _, pair := range record {
+			row[indexes[pair[0]]] = pair[1]
+		}
+
+		rows = append(rows, row)
+	}
+
+	return header, rows
+}
+
+// manifestString converts a decoded JSON or YAML scalar to a metadata value.
+func manifestString(value interface{}) string {
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	return fmt.Sprint(value)
+}
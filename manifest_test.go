@@ -0,0 +1,151 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestManifestFormatRoundTrip(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		err error
+	)
+
+	const metadata = "\"organization\",\"filename\",\"checksum\",\"cdm\",\"table\",\"etl\",\"data-version\"\n\"foo\",\"./data\",\"456\",\"pedsnet\",\"person\",\"http://foo.org/etl\",\"3\"\n"
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(strings.NewReader(metadata)); err != nil {
+		t.Fatalf("ReadMetadata(): error in basic function: %s", err)
+	}
+
+	expected := d.RecordMaps
+
+	for _, format := range []datadirectory.ManifestFormat{datadirectory.JSONFormat, datadirectory.YAMLFormat} {
+
+		b.Reset()
+
+		if err = d.WriteMetadataFormat(&b, format); err != nil {
+			t.Fatalf("WriteMetadataFormat(): error in basic function with format '%s': %s", format, err)
+		}
+
+		d = &datadirectory.DataDirectory{}
+
+		if err = d.ReadMetadataFormat(&b, format); err != nil {
+			t.Fatalf("ReadMetadataFormat(): error in basic function with format '%s': %s", format, err)
+		}
+
+		if !reflect.DeepEqual(d.RecordMaps, expected) {
+			t.Errorf("ReadMetadataFormat(): expected RecordMaps (%v) do not match actual RecordMaps (%v) with format '%s'", expected, d.RecordMaps, format)
+		}
+	}
+
+	b.Reset()
+
+	if err = d.WriteMetadata(&b); err != nil {
+		t.Fatalf("WriteMetadata(): error in basic function: %s", err)
+	}
+
+	if b.String() != metadata {
+		t.Errorf("WriteMetadata(): expected output ('%s') does not match actual output ('%s')", metadata, b.String())
+	}
+
+}
+
+func TestManifestCSVQuotes(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		err error
+	)
+
+	const metadata = "\"organization\",\"filename\",\"checksum\",\"cdm\",\"table\",\"etl\",\"data-version\"\n\"Org \"\"A\"\"\",\"./data\",\"456\",\"pedsnet\",\"person\",\"http://foo.org/etl\",\"3\"\n"
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(strings.NewReader(metadata)); err != nil {
+		t.Fatalf("ReadMetadata(): error in basic function: %s", err)
+	}
+
+	if d.RecordMaps[0]["organization"] != `Org "A"` {
+		t.Fatalf("ReadMetadata(): expected organization ('Org \"A\"') does not match actual organization ('%s')", d.RecordMaps[0]["organization"])
+	}
+
+	expected := d.RecordMaps
+
+	if err = d.WriteMetadata(&b); err != nil {
+		t.Fatalf("WriteMetadata(): error in basic function: %s", err)
+	}
+
+	if b.String() != metadata {
+		t.Errorf("WriteMetadata(): expected output ('%s') does not match actual output ('%s')", metadata, b.String())
+	}
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(&b); err != nil {
+		t.Fatalf("ReadMetadata(): written metadata could not be read: %s", err)
+	}
+
+	if !reflect.DeepEqual(d.RecordMaps, expected) {
+		t.Errorf("ReadMetadata(): expected RecordMaps (%v) do not match actual RecordMaps (%v)", expected, d.RecordMaps)
+	}
+}
+
+func TestReadMetadataYAMLScalars(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		err error
+	)
+
+	const metadata = "- organization: foo\n  filename: ./data\n  checksum: 456\n  cdm: pedsnet\n  table: person\n  etl: http://foo.org/etl\n  data-version: 3\n"
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadataFormat(strings.NewReader(metadata), datadirectory.YAMLFormat); err != nil {
+		t.Fatalf("ReadMetadataFormat(): error in basic function: %s", err)
+	}
+
+	if d.RecordMaps[0]["data-version"] != "3" || d.RecordMaps[0]["checksum"] != "456" {
+		t.Errorf("ReadMetadataFormat(): YAML scalars not read as strings: %v", d.RecordMaps[0])
+	}
+
+}
+
+func TestNewMetadataFormat(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:    "test_data",
+		MetadataFormat: datadirectory.JSONFormat,
+	}
+
+	if d, err = datadirectory.New(cfg); err != nil {
+		t.Fatalf("New(): error in basic function: %s", err)
+	}
+
+	if d.FilePath != filepath.Join("test_data", "metadata.json") {
+		t.Errorf("New(): expected FilePath ('test_data/metadata.json') does not match actual FilePath ('%s')", d.FilePath)
+	}
+
+	cfg.MetadataFormat = "xml"
+
+	if _, err = datadirectory.New(cfg); err == nil {
+		t.Errorf("New(): no error thrown for unsupported metadata format")
+	}
+
+}
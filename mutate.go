@@ -0,0 +1,195 @@
+package datadirectory
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AddFile adds a record for the data file at path, which is either relative
+// to the data directory or an absolute path within it. The file is hashed
+// and the record is filled from the DataDirectory attributes. If table is
+// empty, it is derived from the file name. The table must exist in the
+// DataDirectory model and version in the data models service.
+func (d *DataDirectory) AddFile(path string, table string) error {
+
+	var (
+		relPath     string
+		name        string
+		compression string
+		fi          fs.FileInfo
+		fh          *fileHash
+		err         error
+	)
+
+	if relPath, err = d.dataFilePath(path); err != nil {
+		return err
+	}
+
+	if name, err = fsPath(relPath); err != nil {
+		return err
+	}
+
+	if fi, err = fs.Stat(d.fsys(), name); err != nil {
+		return err
+	}
+
+	if !d.isDataFile(relPath, fi.IsDir()) {
+		return fmt.Errorf("file '%s' is not a data file", relPath)
+	}
+
+	if d.recordIndex(relPath) >= 0 {
+		return fmt.Errorf("file '%s' already listed in metadata", relPath)
+	}
+
+	// Derive the table from the file name if not passed.
+	if table = strings.ToLower(table); table == "" {
+		table, _, _ = splitDataFileName(relPath)
+	}
+
+	if err = d.checkTable(d.Model, d.ModelVersion, table); err != nil {
+		return fmt.Errorf("file '%s' %s", relPath, err)
+	}
+
+	_, compression, _ = splitDataFileName(relPath)
+
+	if fh, err = d.hashFile(relPath, compression, nil); err != nil {
+		return err
+	}
+
+	d.appendRecord(d.newRecordMap(relPath, fh, table))
+
+	return nil
+}
+
+// RemoveFile removes the record for the passed filename, relative to the
+// data directory. The data file itself is left alone.
+func (d *DataDirectory) RemoveFile(filename string) error {
+
+	var i int
+
+	if i = d.recordIndex(filename); i < 0 {
+		return fmt.Errorf("file '%s' not listed in metadata", filename)
+	}
+
+	d.RecordMaps = append(d.RecordMaps[:i], d.RecordMaps[i+1:]...)
+	d.renumberRecords()
+
+	return nil
+}
+
+// UpdateChecksum recalculates the checksum, size and row count of the data
+// file for the passed filename, relative to the data directory.
+func (d *DataDirectory) UpdateChecksum(filename string) error {
+
+	var (
+		i   int
+		fh  *fileHash
+		err error
+	)
+
+	if i = d.recordIndex(filename); i < 0 {
+		return fmt.Errorf("file '%s' not listed in metadata", filename)
+	}
+
+	if fh, err = d.hashFile(d.RecordMaps[i]["filename"], recordCompression(d.RecordMaps[i]), nil); err != nil {
+		return err
+	}
+
+	d.setFileHash(d.RecordMaps[i], fh)
+
+	return nil
+}
+
+// SetTable sets the table of the record for the passed filename, relative to
+// the data directory. The table must exist in the record's model and version
+// in the data models service.
+func (d *DataDirectory) SetTable(filename string, table string) error {
+
+	var (
+		i   int
+		err error
+	)
+
+	if i = d.recordIndex(filename); i < 0 {
+		return fmt.Errorf("file '%s' not listed in metadata", filename)
+	}
+
+	table = strings.ToLower(table)
+
+	if err = d.checkTable(d.RecordMaps[i]["cdm"], d.RecordMaps[i]["cdm-version"], table); err != nil {
+		return fmt.Errorf("line '%s' %s", d.RecordMaps[i]["line"], err)
+	}
+
+	d.RecordMaps[i]["table"] = table
+
+	return nil
+}
+
+// dataFilePath returns the path of a data file relative to the data
+// directory. A relative path is taken to be relative to the data directory.
+func (d *DataDirectory) dataFilePath(path string) (string, error) {
+
+	var (
+		relPath string
+		err     error
+	)
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(d.DirPath, path)
+	}
+
+	if relPath, err = filepath.Rel(d.DirPath, path); err != nil {
+		return "", err
+	}
+
+	if _, err = archiveName(relPath); err != nil {
+		return "", err
+	}
+
+	return relPath, nil
+}
+
+// recordIndex returns the index in RecordMaps of the record for the passed
+// filename, or -1 if there is none.
+func (d *DataDirectory) recordIndex(filename string) int {
+
+	filename = filepath.Clean(filename)
+
+	for i, recordMap := range d.RecordMaps {
+		if filepath.Clean(recordMap["filename"]) == filename {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// appendRecord adds a record to the RecordMaps, setting its "line" value to
+// its position in the metadata file, as renumberRecords does.
+func (d *DataDirectory) appendRecord(recordMap map[string]string) {
+	d.RecordMaps = append(d.RecordMaps, recordMap)
+	recordMap["line"] = strconv.Itoa(len(d.RecordMaps) + 1)
+}
+
+// renumberRecords resets the "line" value of every record to its position in
+// the metadata file.
+func (d *DataDirectory) renumberRecords() {
+	for i, recordMap := range d.RecordMaps {
+		recordMap["line"] = strconv.Itoa(i + 2)
+	}
+}
+
+// checkTable returns an error if the table is not present in the info
+// retrieved from the data models service for the passed model and version.
+// An empty version refers to the latest model version.
+func (d *DataDirectory) checkTable(model string, version string, table string) error {
+
+	if d.modelTable(model, version, table) == nil {
+		return fmt.Errorf("table '%s' not found in data models service for cdm '%s' version '%s'", table, model, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package datadirectory_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestAddFile(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	dir = copyTestData(t)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	for _, name := range []string{"location.csv", filepath.Join(dir, "care_site.csv")} {
+		if err = d.AddFile(name, ""); err != nil {
+			t.Errorf("AddFile(): error in basic function: %s", err)
+		}
+	}
+
+	if err = d.AddFile("provider.csv", "foo"); err == nil {
+		t.Errorf("AddFile(): no error thrown for unknown table")
+	}
+
+	if err = d.AddFile("location.csv", ""); err == nil {
+		t.Errorf("AddFile(): no error thrown for file already listed")
+	}
+
+	if err = d.AddFile("provider.csv", "provider"); err != nil {
+		t.Errorf("AddFile(): error in basic function: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Fatalf("AddFile(): expected number of RecordMaps (3) does not match actual length (%d)", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after AddFile(): %s", err)
+	}
+
+}
+
+func TestRemoveUpdateSetTable(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	dir = copyTestData(t)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.RemoveFile("location.csv"); err != nil {
+		t.Errorf("RemoveFile(): error in basic function: %s", err)
+	}
+
+	if len(d.RecordMaps) != 2 || d.RecordMaps[0]["line"] != "2" {
+		t.Errorf("RemoveFile(): record not removed or lines not renumbered: %v", d.RecordMaps)
+	}
+
+	if err = d.RemoveFile("location.csv"); err == nil {
+		t.Errorf("RemoveFile(): no error thrown for unlisted file")
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), []byte("provider_id\n\"1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("provider.csv"); err != nil {
+		t.Errorf("UpdateChecksum(): error in basic function: %s", err)
+	}
+
+	if err = d.SetTable("provider.csv", "foo"); err == nil {
+		t.Errorf("SetTable(): no error thrown for unknown table")
+	}
+
+	if err = d.SetTable("provider.csv", "Provider"); err != nil {
+		t.Errorf("SetTable(): error in basic function: %s", err)
+	}
+
+	if err = d.WriteMetadataToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after UpdateChecksum(): %s", err)
+	}
+
+}
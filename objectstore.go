@@ -0,0 +1,336 @@
+package datadirectory
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ObjectStore is a flat, key-addressed object storage, such as an S3 bucket.
+// Stat and Get must return an error wrapping fs.ErrNotExist for missing
+// keys.
+type ObjectStore interface {
+	// List returns the objects whose keys begin with prefix.
+	List(prefix string) ([]*ObjectInfo, error)
+	// Stat returns the object with the passed key.
+	Stat(key string) (*ObjectInfo, error)
+	// Get returns the contents of the object with the passed key.
+	Get(key string) (io.ReadCloser, error)
+	// Put stores size bytes read from r as the object with the passed key.
+	Put(key string, r io.Reader, size int64) error
+}
+
+// ObjectInfo describes a stored object. ChecksumSHA256 is the base64 encoded
+// sha256 checksum of the whole object, if the store keeps one, as S3 does
+// for objects uploaded with a full object sha256 checksum. ETags are not
+// used, since they are md5 or multipart checksums rather than sha256.
+type ObjectInfo struct {
+	Key            string
+	Size           int64
+	ModTime        time.Time
+	ChecksumSHA256 string
+}
+
+// objectChecksum returns the hex encoded sha256 checksum of an object, if it
+// is known without reading the object. Composite multipart checksums, with a
+// "-N" part count suffix, are not checksums of the whole object.
+func objectChecksum(info *ObjectInfo) (string, bool) {
+
+	var (
+		sum []byte
+		err error
+	)
+
+	if info.ChecksumSHA256 == "" || strings.Contains(info.ChecksumSHA256, "-") {
+		return "", false
+	}
+
+	if sum, err = base64.StdEncoding.DecodeString(info.ChecksumSHA256); err != nil || len(sum) != 32 {
+		return "", false
+	}
+
+	return hex.EncodeToString(sum), true
+}
+
+// ObjectFS is an fs.FS over the objects under a key prefix in an
+// ObjectStore, so that the prefix can be used as a data directory by setting
+// it as the DataDirectory FS. Directories are implied by "/" separated keys.
+// The metadata file is written back to the store by WriteMetadataToFile.
+type ObjectFS struct {
+	store  ObjectStore
+	prefix string
+}
+
+// NewObjectFS returns an ObjectFS for the objects under prefix in store. An
+// empty prefix is the whole store.
+func NewObjectFS(store ObjectStore, prefix string) *ObjectFS {
+
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+
+	return &ObjectFS{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+// Open opens the named file or directory.
+func (f *ObjectFS) Open(name string) (fs.File, error) {
+
+	var (
+		info *ObjectInfo
+		err  error
+	)
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return &objectDir{fs: f, name: name}, nil
+	}
+
+	if info, err = f.store.Stat(f.prefix + name); err == nil {
+		return &objectFile{fs: f, info: info}, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if _, err = f.ReadDir(name); err != nil {
+		return nil, err
+	}
+
+	return &objectDir{fs: f, name: name}, nil
+}
+
+// Stat returns the file info of the named file or directory.
+func (f *ObjectFS) Stat(name string) (fs.FileInfo, error) {
+
+	var (
+		file fs.File
+		err  error
+	)
+
+	if file, err = f.Open(name); err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return file.Stat()
+}
+
+// ReadDir returns the entries of the named directory, sorted by name. A
+// directory exists if any object key is within it.
+func (f *ObjectFS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	var (
+		dirPrefix string
+		objects   []*ObjectInfo
+		entries   []fs.DirEntry
+		seen      map[string]bool
+		err       error
+	)
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dirPrefix = f.prefix
+
+	if name != "." {
+		dirPrefix += name + "/"
+	}
+
+	if objects, err = f.store.List(dirPrefix); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	if len(objects) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	seen = make(map[string]bool)
+
+	for _, object := range objects {
+
+		var (
+			relKey = strings.TrimPrefix(object.Key, dirPrefix)
+			child  = relKey
+		)
+
+		if i := strings.Index(relKey, "/"); i >= 0 {
+			child = relKey[:i]
+		}
+
+		if child == "" || seen[child] {
+			continue
+		}
+
+		seen[child] = true
+
+		if child == relKey {
+			entries = append(entries, fs.FileInfoToDirEntry(&objectFileInfo{name: child, info: object}))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(&objectFileInfo{name: child}))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// WriteFile stores data as the named file.
+func (f *ObjectFS) WriteFile(name string, data []byte) error {
+
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return f.store.Put(f.prefix+name, bytes.NewReader(data), int64(len(data)))
+}
+
+// objectFile is an open object. Its contents are only fetched when first
+// read, so that stating it is cheap.
+type objectFile struct {
+	fs   *ObjectFS
+	info *ObjectInfo
+	body io.ReadCloser
+}
+
+func (o *objectFile) Stat() (fs.FileInfo, error) {
+	return &objectFileInfo{name: path.Base(o.info.Key), info: o.info}, nil
+}
+
+func (o *objectFile) Read(p []byte) (int, error) {
+
+	var err error
+
+	if o.body == nil {
+		if o.body, err = o.fs.store.Get(o.info.Key); err != nil {
+			return 0, err
+		}
+	}
+
+	return o.body.Read(p)
+}
+
+func (o *objectFile) Close() error {
+
+	if o.body != nil {
+		return o.body.Close()
+	}
+
+	return nil
+}
+
+// objectDir is an open directory.
+type objectDir struct {
+	fs      *ObjectFS
+	name    string
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (o *objectDir) Stat() (fs.FileInfo, error) {
+	return &objectFileInfo{name: path.Base(o.name)}, nil
+}
+
+func (o *objectDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: o.name, Err: errors.New("is a directory")}
+}
+
+func (o *objectDir) Close() error {
+	return nil
+}
+
+func (o *objectDir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	var (
+		entries []fs.DirEntry
+		err     error
+	)
+
+	if !o.read {
+
+		if o.entries, err = o.fs.ReadDir(o.name); err != nil {
+			return nil, err
+		}
+
+		o.read = true
+	}
+
+	if n <= 0 {
+		entries, o.entries = o.entries, nil
+		return entries, nil
+	}
+
+	if len(o.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(o.entries) {
+		n = len(o.entries)
+	}
+
+	entries, o.entries = o.entries[:n], o.entries[n:]
+
+	return entries, nil
+}
+
+// objectFileInfo is the fs.FileInfo of an object, or of a directory if info
+// is nil. The ObjectInfo is returned by Sys.
+type objectFileInfo struct {
+	name string
+	info *ObjectInfo
+}
+
+func (i *objectFileInfo) Name() string {
+	return i.name
+}
+
+func (i *objectFileInfo) Size() int64 {
+
+	if i.info == nil {
+		return 0
+	}
+
+	return i.info.Size
+}
+
+func (i *objectFileInfo) Mode() fs.FileMode {
+
+	if i.info == nil {
+		return fs.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func (i *objectFileInfo) ModTime() time.Time {
+
+	if i.info == nil {
+		return time.Time{}
+	}
+
+	return i.info.ModTime
+}
+
+func (i *objectFileInfo) IsDir() bool {
+	return i.info == nil
+}
+
+func (i *objectFileInfo) Sys() interface{} {
+	return i.info
+}
@@ -0,0 +1,236 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/infomodels/datadirectory"
+)
+
+// memStore is an in-memory ObjectStore.
+type memStore struct {
+	objects   map[string][]byte
+	checksums map[string]string
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		objects:   make(map[string][]byte),
+		checksums: make(map[string]string),
+	}
+}
+
+func (s *memStore) List(prefix string) ([]*datadirectory.ObjectInfo, error) {
+
+	var objects []*datadirectory.ObjectInfo
+
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			info, _ := s.Stat(key)
+			objects = append(objects, info)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+func (s *memStore) Stat(key string) (*datadirectory.ObjectInfo, error) {
+
+	data, ok := s.objects[key]
+
+	if !ok {
+		return nil, fmt.Errorf("object '%s': %w", key, fs.ErrNotExist)
+	}
+
+	return &datadirectory.ObjectInfo{
+		Key:            key,
+		Size:           int64(len(data)),
+		ModTime:        time.Unix(0, 0),
+		ChecksumSHA256: s.checksums[key],
+	}, nil
+}
+
+func (s *memStore) Get(key string) (io.ReadCloser, error) {
+
+	data, ok := s.objects[key]
+
+	if !ok {
+		return nil, fmt.Errorf("object '%s': %w", key, fs.ErrNotExist)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memStore) Put(key string, r io.Reader, size int64) error {
+
+	data, err := io.ReadAll(r)
+
+	if err != nil {
+		return err
+	}
+
+	if int64(len(data)) != size {
+		return fmt.Errorf("object '%s': expected %d bytes, got %d", key, size, len(data))
+	}
+
+	s.objects[key] = data
+	delete(s.checksums, key)
+
+	return nil
+}
+
+// putTestData stores the data files in test_data under prefix.
+func putTestData(t *testing.T, store datadirectory.ObjectStore, prefix string) {
+
+	var (
+		names []string
+		data  []byte
+		err   error
+	)
+
+	if names, err = filepath.Glob(filepath.Join("test_data", "*.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+
+		if filepath.Base(name) == "metadata.csv" {
+			continue
+		}
+
+		if data, err = os.ReadFile(name); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = store.Put(prefix+filepath.Base(name), bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestObjectFS(t *testing.T) {
+
+	var (
+		store *memStore
+		err   error
+	)
+
+	store = newMemStore()
+	putTestData(t, store, "site/extract/")
+	store.Put("site/extract/vocab/concept.csv", strings.NewReader("concept_id\n"), 11)
+	store.Put("site/other.csv", strings.NewReader("x\n"), 2)
+
+	if err = fstest.TestFS(datadirectory.NewObjectFS(store, "site/extract"), "care_site.csv", "location.csv", "provider.csv", "vocab/concept.csv"); err != nil {
+		t.Errorf("NewObjectFS(): %s", err)
+	}
+}
+
+func TestObjectFSDataDirectory(t *testing.T) {
+
+	var (
+		cfg   *datadirectory.Config
+		d     *datadirectory.DataDirectory
+		store *memStore
+		err   error
+	)
+
+	store = newMemStore()
+	putTestData(t, store, "extract/")
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+		FS:           datadirectory.NewObjectFS(store, "extract"),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error listing object store: %s", err)
+	}
+
+	if err = d.WriteMetadataToFile(); err != nil {
+		t.Fatalf("WriteMetadataToFile(): error writing to object store: %s", err)
+	}
+
+	if _, ok := store.objects["extract/metadata.csv"]; !ok {
+		t.Fatalf("WriteMetadataToFile(): metadata.csv not written to object store")
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error reading from object store: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Errorf("ReadMetadataFromFile(): expected 3 records, got %d", len(d.RecordMaps))
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error validating object store: %s", err)
+	}
+}
+
+func TestObjectFSStoredChecksum(t *testing.T) {
+
+	var (
+		cfg   *datadirectory.Config
+		d     *datadirectory.DataDirectory
+		store *memStore
+		sum   [32]byte
+		err   error
+	)
+
+	store = newMemStore()
+	putTestData(t, store, "")
+
+	// A stored full object checksum is used without reading the object.
+	sum = sha256.Sum256([]byte("stored"))
+	store.checksums["location.csv"] = base64.StdEncoding.EncodeToString(sum[:])
+
+	// A composite multipart checksum is not.
+	store.checksums["provider.csv"] = base64.StdEncoding.EncodeToString(sum[:]) + "-2"
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		FS:           datadirectory.NewObjectFS(store, ""),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatal(err)
+	}
+
+	if record := d.RecordByFilename("location.csv"); record == nil || record.Checksum != fmt.Sprintf("%x", sum) {
+		t.Errorf("PopulateMetadataFromData(): stored checksum not used")
+	}
+
+	if record := d.RecordByFilename("provider.csv"); record == nil || record.Checksum != "e784eeeea4b8264cf838c209034d4b8868d036f46d72789f04ac64f30853a636" {
+		t.Errorf("PopulateMetadataFromData(): composite checksum used instead of hashing")
+	}
+}
@@ -0,0 +1,274 @@
+package datadirectory
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Pack writes a tar archive of the DataDirectory to the passed writer. The
+// archive holds a metadata.csv generated from the RecordMaps followed by
+// exactly the listed data files, in metadata order. Each checksum is verified
+// as the file is written, so the packed metadata always matches the packed
+// data.
+func (d *DataDirectory) Pack(w io.Writer) error {
+
+	var (
+		tarWriter *tar.Writer
+		metadata  bytes.Buffer
+		err       error
+	)
+
+	tarWriter = tar.NewWriter(w)
+
+	// Write the metadata first so that Unpack can check entries against it.
+	if err = d.WriteMetadata(&metadata); err != nil {
+		return err
+	}
+
+	if err = tarWriter.WriteHeader(&tar.Header{
+		Name:     "metadata.csv",
+		Mode:     0644,
+		Size:     int64(metadata.Len()),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+
+	if _, err = tarWriter.Write(metadata.Bytes()); err != nil {
+		return err
+	}
+
+	for _, recordMap := range d.RecordMaps {
+		if err = d.packRecord(tarWriter, recordMap); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// PackGzip writes a gzip-compressed tar archive of the DataDirectory to the
+// passed writer. See Pack.
+func (d *DataDirectory) PackGzip(w io.Writer) error {
+
+	var (
+		gzipWriter *gzip.Writer
+		err        error
+	)
+
+	gzipWriter = gzip.NewWriter(w)
+
+	if err = d.Pack(gzipWriter); err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+// packRecord writes the data file for a record to the tar writer, verifying
+// its checksum along the way.
+func (d *DataDirectory) packRecord(tarWriter *tar.Writer, recordMap map[string]string) error {
+
+	var (
+		name     string
+		dataFile fs.File
+		fi       fs.FileInfo
+		start    time.Time
+		sum      hash.Hash
+		err      error
+	)
+
+	if name, err = archiveName(recordMap["filename"]); err != nil {
+		return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+	}
+
+	if dataFile, err = d.openFile(recordMap["filename"]); err != nil {
+		return err
+	}
+
+	defer dataFile.Close()
+
+	if fi, err = dataFile.Stat(); err != nil {
+		return err
+	}
+
+	start = time.Now()
+
+	if err = tarWriter.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     fi.Size(),
+		ModTime:  fi.ModTime(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+
+	sum = sha256.New()
+
+	if _, err = io.Copy(io.MultiWriter(tarWriter, sum), dataFile); err != nil {
+		return err
+	}
+
+	if recordMap["checksum"] != hex.EncodeToString(sum.Sum(nil)) {
+		return fmt.Errorf("line '%s' file '%s' checksum does not match", recordMap["line"], recordMap["filename"])
+	}
+
+	d.logger().Info("packed data file", "file", recordMap["filename"], "table", recordMap["table"], "bytes", fi.Size(), "duration", time.Since(start))
+
+	return nil
+}
+
+// Unpack extracts a tar archive, optionally gzip-compressed, created by Pack
+// into the DataDirectory's DirPath, reads its metadata and validates the
+// result. The archive must begin with metadata.csv and every other entry must
+// be listed in it. The FS attribute is cleared, since the extracted files are
+// read back from disk.
+func (d *DataDirectory) Unpack(r io.Reader) error {
+
+	var (
+		bufReader *bufio.Reader
+		src       io.Reader
+		tarReader *tar.Reader
+		hdr       *tar.Header
+		metadata  bytes.Buffer
+		listed    map[string]map[string]string
+		extracted map[string]bool
+		magic     []byte
+		err       error
+	)
+
+	// Detect gzip compression from the magic number.
+	bufReader = bufio.NewReader(r)
+	src = bufReader
+
+	if magic, err = bufReader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		if src, err = gzip.NewReader(bufReader); err != nil {
+			return err
+		}
+	}
+
+	tarReader = tar.NewReader(src)
+	d.FS = nil
+
+	// Read the metadata.
+	if hdr, err = tarReader.Next(); err != nil {
+		return err
+	}
+
+	if path.Clean(hdr.Name) != "metadata.csv" {
+		return fmt.Errorf("archive entry '%s' found before metadata.csv", hdr.Name)
+	}
+
+	if _, err = io.Copy(&metadata, tarReader); err != nil {
+		return err
+	}
+
+	d.RecordMaps = make([]map[string]string, 0)
+
+	if err = d.ReadMetadata(bytes.NewReader(metadata.Bytes())); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(d.DirPath, 0755); err != nil {
+		return err
+	}
+
+	if err = d.WriteMetadataToFile(); err != nil {
+		return err
+	}
+
+	// Extract the data files, rejecting anything not listed.
+	listed = recordsByFilename(d.RecordMaps)
+	extracted = make(map[string]bool)
+
+	for {
+
+		var name string
+
+		if hdr, err = tarReader.Next(); err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if name, err = archiveName(hdr.Name); err != nil {
+			return err
+		}
+
+		name = filepath.FromSlash(name)
+
+		if _, ok := listed[name]; !ok || hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("archive entry '%s' not listed in metadata", hdr.Name)
+		}
+
+		if extracted[name] {
+			return fmt.Errorf("archive entry '%s' found more than once", hdr.Name)
+		}
+
+		extracted[name] = true
+
+		if err = extractFile(filepath.Join(d.DirPath, name), tarReader); err != nil {
+			return err
+		}
+	}
+
+	return d.Validate()
+}
+
+// extractFile writes the contents of r to a new file at path, creating
+// parent directories as needed.
+func extractFile(path string, r io.Reader) error {
+
+	var (
+		file *os.File
+		err  error
+	)
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644); err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// archiveName returns the slash-separated archive entry name for a metadata
+// filename, rejecting names outside the data directory.
+func archiveName(filename string) (string, error) {
+
+	var name = path.Clean(filepath.ToSlash(filename))
+
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("filename '%s' is outside the data directory", filename)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,81 @@
+package datadirectory_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestPackUnpack(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		b   bytes.Buffer
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	if err = d.PackGzip(&b); err != nil {
+		t.Fatalf("PackGzip(): error in basic function: %s", err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: t.TempDir(),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.Unpack(&b); err != nil {
+		t.Errorf("Unpack(): error in basic function: %s", err)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Errorf("Unpack(): expected number of RecordMaps (3) does not match actual length (%d)", len(d.RecordMaps))
+	}
+
+}
+
+func TestUnpackUnlistedEntry(t *testing.T) {
+
+	var (
+		cfg       *datadirectory.Config
+		d         *datadirectory.DataDirectory
+		b         bytes.Buffer
+		tarWriter *tar.Writer
+		err       error
+	)
+
+	const metadata = "organization,filename,checksum,cdm,table,etl\n"
+
+	tarWriter = tar.NewWriter(&b)
+
+	for _, name := range []string{"metadata.csv", "extra.csv"} {
+		tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(metadata)), Typeflag: tar.TypeReg})
+		tarWriter.Write([]byte(metadata))
+	}
+
+	tarWriter.Close()
+
+	cfg = &datadirectory.Config{
+		DataDirPath: t.TempDir(),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.Unpack(&b); err == nil {
+		t.Errorf("Unpack(): no error thrown for entry not listed in metadata")
+	}
+
+}
@@ -1,26 +1,37 @@
 package datadirectory
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
 	"io"
-	"log"
-	"os"
+	"io/fs"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // PopulateMetadataFromData fills the DataDirectory with metadata from the
 // data files. Also, any information missing from the DataDirectory object is
 // collected through command line prompts.
 func (d *DataDirectory) PopulateMetadataFromData() error {
+	return d.PopulateContext(context.Background())
+}
+
+// PopulateContext is PopulateMetadataFromData, stopping with the context
+// error once ctx is done and reporting the progress of hashing the data files
+// to the DataDirectory Progress attribute, if set.
+func (d *DataDirectory) PopulateContext(ctx context.Context) error {
 
 	var (
 		modelChoices   []string
 		versionChoices []string
+		files          []string
+		total          int64
+		tracker        *progressTracker
 		err            error
 	)
 
@@ -74,83 +85,259 @@ func (d *DataDirectory) PopulateMetadataFromData() error {
 		}
 	}*/
 
-	// Write metadata rows.
-	if err = filepath.Walk(d.DirPath, d.populateRecord); err != nil {
+	// Find the data files and their total size.
+	err = fs.WalkDir(d.fsys(), ".", func(path string, entry fs.DirEntry, inErr error) error {
+
+		var (
+			relPath string
+			fi      fs.FileInfo
+			err     error
+		)
+
+		// Return any error passed in.
+		if err = inErr; err != nil {
+			return err
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		// Get file path relative to the base data dir.
+		relPath = filepath.FromSlash(path)
+
+		// Skip directories, non-csv files, and the metadata file itself.
+		if !d.isDataFile(relPath, entry.IsDir()) {
+			return nil
+		}
+
+		if fi, err = entry.Info(); err != nil {
+			return err
+		}
+
+		files = append(files, relPath)
+		total += fi.Size()
+
+		return nil
+	})
+
+	if err != nil {
 		return err
 	}
 
+	tracker = newProgressTracker(ctx, d.Progress, total)
+
+	// Write metadata rows.
+	for _, relPath := range files {
+		if err = d.populateRecord(relPath, tracker); err != nil {
+			return err
+		}
+	}
+
 	return nil
 
 }
 
-// populateRecord is a walk function that can be passed to filepath.Walk in
-// order to fill the DataDirectory file metadata for each file in the
-// directory.
-func (d *DataDirectory) populateRecord(path string, fi os.FileInfo, inErr error) error {
+// populateRecord fills the DataDirectory file metadata for the data file at
+// relPath, relative to the data directory.
+func (d *DataDirectory) populateRecord(relPath string, tracker *progressTracker) error {
 
 	var (
-		relPath   string
-		table     string
-		tFound    bool
-		dataFile  *os.File
-		sum       hash.Hash
-		sumString string
-		recordMap map[string]string
-		err       error
+		table       string
+		compression string
+		fh          *fileHash
+		start       time.Time
+		recordMap   map[string]string
+		err         error
 	)
 
-	// Return any error passed in.
-	if err = inErr; err != nil {
+	if table, err = d.tableForFile(relPath); err != nil {
 		return err
 	}
 
-	// Get file path relative to the base data dir.
-	if relPath, err = filepath.Rel(d.DirPath, path); err != nil {
+	start = time.Now()
+
+	_, compression, _ = splitDataFileName(relPath)
+
+	if fh, err = d.hashFile(relPath, compression, tracker); err != nil {
 		return err
 	}
 
-	// Skip directories, non-csv files, and the metadata file itself.
-	if fi.IsDir() || filepath.Ext(path) != ".csv" || relPath == "metadata.csv" {
-		return nil
-	}
+	d.logger().Info("calculated checksum", "file", relPath, "table", table, "bytes", fh.size, "duration", time.Since(start))
+
+	recordMap = d.newRecordMap(relPath, fh, table)
+	d.appendRecord(recordMap)
 
-	// If file name is present in the info retrieved from data models
-	// service, use it. Otherwise, collect table name from STDIN.
-	table = strings.TrimSuffix(filepath.Base(path), ".csv")
+	return nil
+}
+
+// isDataFile reports whether the file at relPath, relative to the data
+// directory, should be listed in the metadata. Directories, non-csv files,
+// and the metadata file itself are excluded. Compressed csv files are
+// included.
+func (d *DataDirectory) isDataFile(relPath string, isDir bool) bool {
+
+	var metaPath, _ = d.metadataPath()
+
+	_, _, ok := splitDataFileName(relPath)
+
+	relPath = filepath.ToSlash(relPath)
+
+	return !isDir && ok && relPath != "metadata.csv" && relPath != metaPath
+}
+
+// tableForFile returns the table name for the data file at path. If the file
+// name is present in the info retrieved from data models service, it is used.
+// Otherwise, the table name is collected from STDIN.
+func (d *DataDirectory) tableForFile(path string) (string, error) {
+
+	var (
+		table string
+		err   error
+	)
+
+	table, _, _ = splitDataFileName(path)
 
 	for _, serviceTable := range d.serviceModels[d.Model][d.ModelVersion] {
 		if table == serviceTable {
-			tFound = true
-			break
+			return table, nil
 		}
 	}
 
-	if !tFound {
-		if table, err = collectInput(fmt.Sprintf("table name for '%s'", path), d.serviceModels[d.Model][d.ModelVersion]); err != nil {
-			return err
-		}
-		table = strings.ToLower(table)
+	if table, err = collectInput(fmt.Sprintf("table name for '%s'", path), d.serviceModels[d.Model][d.ModelVersion]); err != nil {
+		return "", err
 	}
 
-	// Calculate checksum.
-	if dataFile, err = os.Open(path); err != nil {
-		return err
+	return strings.ToLower(table), nil
+}
+
+// fileHash is the hex encoded sha256 checksum, stored size in bytes and
+// number of csv rows, not counting the header, of a data file. size and rows
+// are -1 if they are not known.
+type fileHash struct {
+	checksum string
+	size     int64
+	rows     int64
+}
+
+// hashFile calculates the fileHash of the data file with the passed filename,
+// relative to the data directory, reporting to the tracker, which may be nil.
+// A compressed file's rows are counted as it is decompressed, in the same
+// pass as hashing, unless it is corrupt. The checksum kept by an object store
+// is used instead, if there is one, in which case the file is not read and
+// its rows are not counted.
+func (d *DataDirectory) hashFile(filename string, compression string, tracker *progressTracker) (*fileHash, error) {
+
+	var (
+		dataFile fs.File
+		fi       fs.FileInfo
+		sum      hash.Hash
+		rows     *csvRowCounter
+		raw      *readErrReader
+		fh       *fileHash
+		err      error
+	)
+
+	if err = tracker.err(); err != nil {
+		return nil, err
+	}
+
+	if dataFile, err = d.openFile(filename); err != nil {
+		return nil, err
 	}
 
 	defer dataFile.Close()
 
+	if fi, err = dataFile.Stat(); err != nil {
+		return nil, err
+	}
+
+	tracker.startFile(filename)
+
+	if info, ok := fi.Sys().(*ObjectInfo); ok {
+		if sumString, ok := objectChecksum(info); ok {
+			tracker.add(fi.Size())
+			tracker.finishFile()
+			return &fileHash{checksum: sumString, size: fi.Size(), rows: -1}, nil
+		}
+	}
+
 	sum = sha256.New()
+	rows = &csvRowCounter{}
+	raw = &readErrReader{r: tracker.reader(dataFile)}
+	fh = &fileHash{size: fi.Size()}
 
-	log.Printf("metadata: calculating '%s' checksum", filepath.Base(path))
-	if _, err = io.Copy(sum, dataFile); err != nil {
-		return err
+	// Corrupt compressed files are reported by Validate, so hash the rest of
+	// the file without counting its rows.
+	if err = hashDataFile(raw, sum, rows, compression); err != nil {
+
+		if raw.err != nil || compression == "" {
+			return nil, err
+		}
+
+		if _, err = io.Copy(sum, raw); err != nil {
+			return nil, err
+		}
+
+		rows = nil
 	}
 
-	sumString = hex.EncodeToString(sum.Sum(nil))
+	tracker.finishFile()
+
+	fh.checksum = hex.EncodeToString(sum.Sum(nil))
+	fh.rows = -1
+
+	if rows != nil {
+		fh.rows = dataRows(rows)
+	}
+
+	return fh, nil
+}
+
+// readErrReader is a reader that keeps the first error, other than io.EOF,
+// of the underlying reader.
+type readErrReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readErrReader) Read(p []byte) (int, error) {
+
+	n, err := r.r.Read(p)
+
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+
+	return n, err
+}
+
+// dataRows returns the number of rows counted, not counting the header.
+func dataRows(rows *csvRowCounter) int64 {
+
+	if rows.count() == 0 {
+		return 0
+	}
+
+	return rows.count() - 1
+}
+
+// newRecordMap creates a map of header values to record values for a data
+// file, using the DataDirectory attributes for everything not specific to
+// the file. The "line" value is left to the caller. Optional header values
+// needed by the record are added to the DataDirectory header.
+func (d *DataDirectory) newRecordMap(relPath string, fh *fileHash, table string) map[string]string {
+
+	var (
+		compression string
+		recordMap   map[string]string
+	)
+
+	if _, compression, _ = splitDataFileName(relPath); compression != "" {
+		d.addHeader("compression")
+	}
 
-	// Create map of header values to record values.
 	recordMap = make(map[string]string)
-	d.RecordMaps = append(d.RecordMaps, recordMap)
 
 	for _, val := range d.header {
 		switch val {
@@ -158,8 +345,6 @@ func (d *DataDirectory) populateRecord(path string, fi os.FileInfo, inErr error)
 			recordMap[val] = d.Site
 		case "filename":
 			recordMap[val] = relPath
-		case "checksum":
-			recordMap[val] = sumString
 		case "cdm":
 			recordMap[val] = d.Model
 		case "cdm-version":
@@ -170,12 +355,78 @@ func (d *DataDirectory) populateRecord(path string, fi os.FileInfo, inErr error)
 			recordMap[val] = d.Etl
 		case "data-version":
 			recordMap[val] = d.DataVersion
+		case "compression":
+			recordMap[val] = compression
 		}
 	}
 
-	recordMap["line"] = strconv.Itoa(len(d.RecordMaps) + 1)
+	d.setFileHash(recordMap, fh)
 
-	return nil
+	return recordMap
+}
+
+// setFileHash sets the checksum and, if known, the size and row count of a
+// record, adding the size and row count to the DataDirectory header. Values
+// that are not known are cleared.
+func (d *DataDirectory) setFileHash(recordMap map[string]string, fh *fileHash) {
+
+	recordMap["checksum"] = fh.checksum
+
+	for val, n := range map[string]int64{"row-count": fh.rows, "size": fh.size} {
+
+		if n < 0 {
+			delete(recordMap, val)
+			continue
+		}
+
+		d.addHeader(val)
+		recordMap[val] = strconv.FormatInt(n, 10)
+	}
+}
+
+// addHeader adds an optional value to the DataDirectory header if it is not
+// already present, keeping optional values in their optionalHeader order.
+func (d *DataDirectory) addHeader(val string) {
+
+	var (
+		header []string
+		pos    int
+	)
+
+	for _, headerVal := range d.header {
+		if headerVal == val {
+			return
+		}
+	}
+
+	// Insert before the first optional value that belongs after it.
+	pos = len(d.header)
+
+	for i, headerVal := range d.header {
+		if optionalIndex(headerVal) > optionalIndex(val) {
+			pos = i
+			break
+		}
+	}
+
+	// Copy so the shared canonical header is never modified.
+	header = make([]string, 0, len(d.header)+1)
+	header = append(header, d.header[:pos]...)
+	header = append(header, val)
+	d.header = append(header, d.header[pos:]...)
+}
+
+// optionalIndex returns the position of val in optionalHeader, or -1 if it
+// is not an optional value.
+func optionalIndex(val string) int {
+
+	for i, optionalVal := range optionalHeader {
+		if optionalVal == val {
+			return i
+		}
+	}
+
+	return -1
 }
 
 // collectInput collects command line input using a provided prompt string. If
@@ -58,8 +58,8 @@ func TestPopulateLenRecord(t *testing.T) {
 		t.Errorf("PopulateMetadataFromData(): error in basic function: %s", err)
 	}
 
-	if len(d.RecordMaps[0]) != 9 {
-		t.Errorf("PopulateMetadataFromData(): expected length of RecordMap (9) does not match actual length (%d)", len(d.RecordMaps[0]))
+	if len(d.RecordMaps[0]) != 11 {
+		t.Errorf("PopulateMetadataFromData(): expected length of RecordMap (11) does not match actual length (%d)", len(d.RecordMaps[0]))
 	}
 
 }
@@ -100,3 +100,40 @@ func TestPopulateRecordContent(t *testing.T) {
 	}
 
 }
+
+func TestPopulateRowCountSize(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		err error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  "test_data",
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error in basic function: %s", err)
+	}
+
+	for _, record := range d.Records() {
+
+		var rows = map[string]string{"location": "1", "care_site": "3", "provider": "3"}[record.Table]
+
+		if record.RowCount != rows {
+			t.Errorf("PopulateMetadataFromData(): expected row count of '%s' (%s) does not match actual row count (%s)", record.Filename, rows, record.RowCount)
+		}
+
+		if record.Size == "" || record.Size == "0" {
+			t.Errorf("PopulateMetadataFromData(): missing size of '%s'", record.Filename)
+		}
+	}
+}
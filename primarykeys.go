@@ -0,0 +1,473 @@
+package datadirectory
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default maximum number of primary key values held in memory per table.
+const defaultKeyMemoryLimit = 1000000
+
+// Maximum number of example rows kept for each kind of primary key violation.
+const maxKeyExamples = 5
+
+// PrimaryKeyViolation reports the rows of a table with an empty value in any
+// primary key field, or with the same primary key as an earlier row. Counts
+// are of all such rows, of which at most a few are kept as examples.
+type PrimaryKeyViolation struct {
+	Table          string
+	Fields         []string
+	NullCount      int64
+	NullRows       []*KeyRow
+	DuplicateCount int64
+	DuplicateRows  []*KeyRow
+}
+
+// KeyRow is a row of a data file. Values are its key values, in field order.
+type KeyRow struct {
+	Filename string
+	Line     int
+	Values   []string
+}
+
+// ValidatePrimaryKeys checks that the primary key, as defined by the data
+// models service, of every table listed in the metadata is present and
+// unique across all of the table's data files, returning a violation for
+// each table that is not. Once more than KeyMemoryLimit keys of a table have
+// been read, they are spilled to sorted temporary files which are merged to
+// find duplicates, so tables of any size can be checked.
+func (d *DataDirectory) ValidatePrimaryKeys() ([]*PrimaryKeyViolation, error) {
+
+	var (
+		violations []*PrimaryKeyViolation
+		err        error
+	)
+
+	for _, table := range d.Tables() {
+
+		var (
+			record    = d.RecordsByTable(table)[0]
+			modelDef  = d.modelTable(record.CDM, record.CDMVersion, table)
+			violation *PrimaryKeyViolation
+		)
+
+		if modelDef == nil || len(modelDef.PrimaryKey) == 0 {
+			continue
+		}
+
+		if violation, err = d.checkPrimaryKey(table, modelDef.PrimaryKey); err != nil {
+			return nil, err
+		}
+
+		if violation.NullCount > 0 || violation.DuplicateCount > 0 {
+			violations = append(violations, violation)
+		}
+	}
+
+	return violations, nil
+}
+
+// checkPrimaryKey reads every row of a table, counting rows with null or
+// duplicate values for the passed primary key fields.
+func (d *DataDirectory) checkPrimaryKey(table string, fields []string) (*PrimaryKeyViolation, error) {
+
+	var (
+		reader    *TableReader
+		keys      *keySpiller
+		columns   []int
+		violation *PrimaryKeyViolation
+		err       error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	violation = &PrimaryKeyViolation{
+		Table:  table,
+		Fields: fields,
+	}
+
+	keys = newKeySpiller(d.KeyMemoryLimit)
+	defer keys.close()
+
+	duplicate := func(row *KeyRow) {
+
+		violation.DuplicateCount++
+
+		if len(violation.DuplicateRows) < maxKeyExamples {
+			violation.DuplicateRows = append(violation.DuplicateRows, row)
+		}
+	}
+
+	for reader.Next() {
+
+		var (
+			value string
+			ok    bool
+			dup   bool
+		)
+
+		if reader.FileChanged() {
+			if columns, err = reader.Columns(fields); err != nil {
+				return nil, err
+			}
+		}
+
+		if value, ok = keyValue(reader.Values(), columns); !ok {
+
+			violation.NullCount++
+
+			if len(violation.NullRows) < maxKeyExamples {
+				violation.NullRows = append(violation.NullRows, &KeyRow{
+					Filename: reader.Filename(),
+					Line:     reader.Line(),
+					Values:   fieldValues(reader.Values(), columns),
+				})
+			}
+
+			continue
+		}
+
+		if dup, err = keys.add(value, reader.Filename(), reader.Line()); err != nil {
+			return nil, err
+		}
+
+		if dup {
+			duplicate(&KeyRow{
+				Filename: reader.Filename(),
+				Line:     reader.Line(),
+				Values:   strings.Split(value, "\x00"),
+			})
+		}
+	}
+
+	if err = reader.Err(); err != nil {
+		return nil, err
+	}
+
+	// Find the duplicates across spilled keys.
+	if err = keys.mergeDuplicates(duplicate); err != nil {
+		return nil, err
+	}
+
+	return violation, nil
+}
+
+// fieldValues returns the values of the passed columns of a row.
+func fieldValues(row []string, columns []int) []string {
+
+	var values = make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		values = append(values, row[column])
+	}
+
+	return values
+}
+
+// keyLocation is where a key was first seen.
+type keyLocation struct {
+	filename string
+	line     int
+}
+
+// keySpiller is a set of keys that holds at most limit keys in memory,
+// spilling them to sorted run files in a temporary directory beyond that.
+// Duplicates held in memory are found as keys are added; duplicates across
+// runs are found by merging the runs.
+type keySpiller struct {
+	limit  int
+	keys   map[string]keyLocation
+	dir    string
+	runs   []string
+	closed bool
+}
+
+// newKeySpiller returns a keySpiller holding at most limit keys in memory,
+// or defaultKeyMemoryLimit if limit is not positive.
+func newKeySpiller(limit int) *keySpiller {
+
+	if limit <= 0 {
+		limit = defaultKeyMemoryLimit
+	}
+
+	return &keySpiller{
+		limit: limit,
+		keys:  make(map[string]keyLocation),
+	}
+}
+
+// add adds a key, reporting whether it duplicates a key held in memory.
+func (s *keySpiller) add(key string, filename string, line int) (bool, error) {
+
+	if _, ok := s.keys[key]; ok {
+		return true, nil
+	}
+
+	s.keys[key] = keyLocation{filename, line}
+
+	if len(s.keys) >= s.limit {
+		return false, s.spill()
+	}
+
+	return false, nil
+}
+
+// spill writes the keys held in memory to a new run file, in sorted order.
+func (s *keySpiller) spill() error {
+
+	var (
+		sorted []string
+		file   *os.File
+		w      *bufio.Writer
+		err    error
+	)
+
+	if len(s.keys) == 0 {
+		return nil
+	}
+
+	if s.dir == "" {
+		if s.dir, err = os.MkdirTemp("", "datadirectory-keys-"); err != nil {
+			return err
+		}
+	}
+
+	for key := range s.keys {
+		sorted = append(sorted, key)
+	}
+
+	sort.Strings(sorted)
+
+	if file, err = os.Create(filepath.Join(s.dir, "run-"+strconv.Itoa(len(s.runs)))); err != nil {
+		return err
+	}
+
+	w = bufio.NewWriter(file)
+
+	for _, key := range sorted {
+		loc := s.keys[key]
+		writeRunString(w, key)
+		writeRunString(w, loc.filename)
+		writeRunString(w, strconv.Itoa(loc.line))
+	}
+
+	if err = w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	s.runs = append(s.runs, file.Name())
+	s.keys = make(map[string]keyLocation)
+
+	return nil
+}
+
+// mergeDuplicates spills any keys held in memory, if keys have been spilled
+// before, and merges the runs, calling duplicate for each key already seen
+// in an earlier run.
+func (s *keySpiller) mergeDuplicates(duplicate func(row *KeyRow)) error {
+
+	var (
+		files   []*os.File
+		entries runHeap
+		prev    *runEntry
+		err     error
+	)
+
+	if len(s.runs) == 0 {
+		return nil
+	}
+
+	if err = s.spill(); err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for i, run := range s.runs {
+
+		var (
+			file  *os.File
+			entry *runEntry
+		)
+
+		if file, err = os.Open(run); err != nil {
+			return err
+		}
+
+		files = append(files, file)
+
+		if entry, err = readRunEntry(bufio.NewReader(file), i); err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	heap.Init(&entries)
+
+	for entries.Len() > 0 {
+
+		var (
+			entry = heap.Pop(&entries).(*runEntry)
+			next  *runEntry
+		)
+
+		if prev != nil && prev.key == entry.key {
+			duplicate(&KeyRow{
+				Filename: entry.filename,
+				Line:     entry.line,
+				Values:   strings.Split(entry.key, "\x00"),
+			})
+		} else {
+			prev = entry
+		}
+
+		if next, err = readRunEntry(entry.r, entry.run); err == nil {
+			heap.Push(&entries, next)
+		} else if err != io.EOF {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close removes any run files.
+func (s *keySpiller) close() error {
+
+	if s.closed || s.dir == "" {
+		return nil
+	}
+
+	s.closed = true
+
+	return os.RemoveAll(s.dir)
+}
+
+// runEntry is a key read from a run file, with the reader for the rest of
+// the run.
+type runEntry struct {
+	key      string
+	filename string
+	line     int
+	run      int
+	r        *bufio.Reader
+}
+
+// readRunEntry reads the next entry of a run.
+func readRunEntry(r *bufio.Reader, run int) (*runEntry, error) {
+
+	var (
+		entry = &runEntry{run: run, r: r}
+		line  string
+		err   error
+	)
+
+	if entry.key, err = readRunString(r); err != nil {
+		return nil, err
+	}
+
+	if entry.filename, err = readRunString(r); err != nil {
+		return nil, err
+	}
+
+	if line, err = readRunString(r); err != nil {
+		return nil, err
+	}
+
+	if entry.line, err = strconv.Atoi(line); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// writeRunString writes a length prefixed string to a run.
+func writeRunString(w *bufio.Writer, s string) {
+
+	var buf [binary.MaxVarintLen64]byte
+
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(s)))])
+	w.WriteString(s)
+}
+
+// readRunString reads a length prefixed string from a run.
+func readRunString(r *bufio.Reader) (string, error) {
+
+	var (
+		n   uint64
+		buf []byte
+		err error
+	)
+
+	if n, err = binary.ReadUvarint(r); err != nil {
+		return "", err
+	}
+
+	buf = make([]byte, n)
+
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// runHeap orders run entries by key and, for equal keys, by run, so that
+// the first occurrence of a key is popped first.
+type runHeap []*runEntry
+
+func (h runHeap) Len() int {
+	return len(h)
+}
+
+func (h runHeap) Less(i, j int) bool {
+
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+
+	return h[i].run < h[j].run
+}
+
+func (h runHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *runHeap) Push(x interface{}) {
+	*h = append(*h, x.(*runEntry))
+}
+
+func (h *runHeap) Pop() interface{} {
+
+	var (
+		old   = *h
+		entry = old[len(old)-1]
+	)
+
+	*h = old[:len(old)-1]
+
+	return entry
+}
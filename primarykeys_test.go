@@ -0,0 +1,110 @@
+package datadirectory_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestValidatePrimaryKeys(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		violations []*datadirectory.PrimaryKeyViolation
+		err        error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if violations, err = d.ValidatePrimaryKeys(); err != nil {
+		t.Fatalf("ValidatePrimaryKeys(): error in basic function: %s", err)
+	}
+
+	if len(violations) != 0 {
+		t.Errorf("ValidatePrimaryKeys(): expected no violations in test data, got %d", len(violations))
+	}
+}
+
+func TestValidatePrimaryKeysViolations(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		dir        string
+		violations []*datadirectory.PrimaryKeyViolation
+		err        error
+	)
+
+	dir = copyTestData(t)
+
+	// Duplicate a provider within the file and across files, and leave one
+	// without a key.
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), []byte("provider_id,care_site_id\n\"1\",\"1\"\n\"2\",\"1\"\n\"1\",\"2\"\n,\"2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "provider_2.csv"), []byte("provider_id\n\"3\"\n\"2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Model = "pedsnet"
+	d.ModelVersion = "2.1.0"
+
+	if err = d.AddFile("provider_2.csv", "provider"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, limit := range []int{0, 1, 2} {
+
+		d.KeyMemoryLimit = limit
+
+		if violations, err = d.ValidatePrimaryKeys(); err != nil {
+			t.Fatalf("ValidatePrimaryKeys(): error with key memory limit %d: %s", limit, err)
+		}
+
+		if len(violations) != 1 || violations[0].Table != "provider" {
+			t.Fatalf("ValidatePrimaryKeys(): expected a provider violation with key memory limit %d, got %d violations", limit, len(violations))
+		}
+
+		if violations[0].NullCount != 1 || violations[0].NullRows[0].Line != 5 {
+			t.Errorf("ValidatePrimaryKeys(): expected null key on line 5 with key memory limit %d, got %d", limit, violations[0].NullCount)
+		}
+
+		if violations[0].DuplicateCount != 2 {
+			t.Fatalf("ValidatePrimaryKeys(): expected 2 duplicates with key memory limit %d, got %d", limit, violations[0].DuplicateCount)
+		}
+
+		var rows []string
+
+		for _, row := range violations[0].DuplicateRows {
+			rows = append(rows, fmt.Sprintf("%s:%d:%s", row.Filename, row.Line, strings.Join(row.Values, ",")))
+		}
+
+		if strings.Join(rows, " ") != "provider.csv:4:1 provider_2.csv:3:2" {
+			t.Errorf("ValidatePrimaryKeys(): unexpected duplicate rows with key memory limit %d: %v", limit, rows)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package datadirectory
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of most frequent values reported for each column, and the number of
+// candidate values tracked to find them.
+const (
+	profileTopValues  = 5
+	profileCandidates = 100
+)
+
+// Number of index bits of the distinct count estimators, giving 4096
+// registers and a standard error of about 1.6%.
+const hllBits = 12
+
+// ProfileReport is a profile of the data files of a DataDirectory, keyed by
+// table.
+type ProfileReport struct {
+	Tables map[string]*TableProfile `json:"tables"`
+}
+
+// TableProfile is a profile of the data files of a single table.
+type TableProfile struct {
+	Files   []string         `json:"files"`
+	Rows    int64            `json:"rows"`
+	Columns []*ColumnProfile `json:"columns"`
+}
+
+// ColumnProfile is a profile of a single column. Type is the field type in
+// the data models service. Distinct is an estimate. Min and Max are only set
+// for numeric and date or time columns. TopValues are the most frequent
+// values; their counts are upper bounds when a column has many values.
+type ColumnProfile struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type,omitempty"`
+	Nulls     int64         `json:"nulls"`
+	NullRate  float64       `json:"null_rate"`
+	Distinct  int64         `json:"distinct"`
+	Min       string        `json:"min,omitempty"`
+	Max       string        `json:"max,omitempty"`
+	TopValues []*ValueCount `json:"top_values"`
+}
+
+// ValueCount is a value and the number of times it occurs.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Profile reads every data file listed in the metadata once and returns a
+// profile of each table: its row count and, for each column, the null rate,
+// an estimate of the number of distinct values, the minimum and maximum of
+// numeric and date or time columns, and the most frequent values. Checksums
+// are verified in the same pass, so a profile also validates the checksums.
+func (d *DataDirectory) Profile() (*ProfileReport, error) {
+
+	var (
+		report *ProfileReport
+		err    error
+	)
+
+	report = &ProfileReport{
+		Tables: make(map[string]*TableProfile),
+	}
+
+	for _, table := range d.Tables() {
+		if report.Tables[table], err = d.profileTable(table); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// profileTable profiles the data files of a table.
+func (d *DataDirectory) profileTable(table string) (*TableProfile, error) {
+
+	var (
+		reader   *TableReader
+		record   = d.RecordsByTable(table)[0]
+		modelDef = d.modelTable(record.CDM, record.CDMVersion, table)
+		profile  *TableProfile
+		columns  map[string]*columnProfiler
+		order    []string
+		current  []*columnProfiler
+		err      error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	reader.VerifyChecksums = true
+
+	profile = &TableProfile{}
+	columns = make(map[string]*columnProfiler)
+
+	for _, tableRecord := range d.RecordsByTable(table) {
+		profile.Files = append(profile.Files, tableRecord.Filename)
+	}
+
+	for reader.Next() {
+
+		// Match the columns of each file by name. Only the first of columns
+		// with the same name is profiled.
+		if reader.FileChanged() {
+
+			var seen = make(map[string]bool)
+
+			current = make([]*columnProfiler, len(reader.Header()))
+
+			for i, column := range reader.Header() {
+
+				name := strings.ToLower(column)
+
+				if seen[name] {
+					continue
+				}
+
+				seen[name] = true
+
+				if columns[name] == nil {
+					columns[name] = newColumnProfiler(name, modelFieldType(modelDef, name), profile.Rows)
+					order = append(order, name)
+				}
+
+				current[i] = columns[name]
+			}
+		}
+
+		profile.Rows++
+
+		for i, value := range reader.Values() {
+			if i < len(current) && current[i] != nil {
+				current[i].add(value)
+			}
+		}
+
+		// Columns missing from the current file are null.
+		for _, name := range order {
+			if columns[name].rows < profile.Rows {
+				columns[name].add("")
+			}
+		}
+	}
+
+	if err = reader.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, name := range order {
+		profile.Columns = append(profile.Columns, columns[name].profile())
+	}
+
+	return profile, nil
+}
+
+// modelFieldType returns the type of the named field in a model table, or an
+// empty string if it is not defined.
+func modelFieldType(modelDef *modelTable, name string) string {
+
+	if modelDef == nil {
+		return ""
+	}
+
+	for _, field := range modelDef.Fields {
+		if field.Name == name {
+			return strings.ToLower(field.Type)
+		}
+	}
+
+	return ""
+}
+
+// WriteJSON writes the report as indented JSON to the passed writer.
+func (p *ProfileReport) WriteJSON(w io.Writer) error {
+
+	var encoder *json.Encoder
+
+	encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(p)
+}
+
+// WriteMarkdown writes the report to the passed writer as a Markdown section
+// per table, in table name order, each with a row per column.
+func (p *ProfileReport) WriteMarkdown(w io.Writer) error {
+
+	var (
+		tables []string
+		err    error
+	)
+
+	for table := range p.Tables {
+		tables = append(tables, table)
+	}
+
+	sort.Strings(tables)
+
+	for i, table := range tables {
+
+		var profile = p.Tables[table]
+
+		if i > 0 {
+			if _, err = fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		if _, err = fmt.Fprintf(w, "## %s\n\nFiles: %s\n\nRows: %d\n\n", table, strings.Join(profile.Files, ", "), profile.Rows); err != nil {
+			return err
+		}
+
+		if _, err = fmt.Fprintln(w, "| Column | Type | Null rate | Distinct | Min | Max | Top values |\n| --- | --- | --- | --- | --- | --- | --- |"); err != nil {
+			return err
+		}
+
+		for _, column := range profile.Columns {
+
+			var top []string
+
+			for _, value := range column.TopValues {
+				top = append(top, fmt.Sprintf("%s (%d)", markdownEscape(value.Value), value.Count))
+			}
+
+			if _, err = fmt.Fprintf(w, "| %s | %s | %.1f%% | %d | %s | %s | %s |\n", column.Name, column.Type, column.NullRate*100, column.Distinct, markdownEscape(column.Min), markdownEscape(column.Max), strings.Join(top, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// markdownEscape escapes a value for a Markdown table cell.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ", "\r", "").Replace(s)
+}
+
+// columnProfiler accumulates the profile of a column, one value at a time.
+type columnProfiler struct {
+	name     string
+	typ      string
+	rows     int64
+	nulls    int64
+	distinct *hyperLogLog
+	min      string
+	max      string
+	minKey   float64
+	maxKey   float64
+	top      *topValues
+}
+
+// newColumnProfiler returns a profiler for a column of the passed model
+// type. Rows read before the column was first seen are counted as nulls.
+func newColumnProfiler(name string, typ string, nulls int64) *columnProfiler {
+	return &columnProfiler{
+		name:     name,
+		typ:      typ,
+		rows:     nulls,
+		nulls:    nulls,
+		distinct: newHyperLogLog(),
+		top:      &topValues{index: make(map[string]*valueCandidate)},
+	}
+}
+
+// add adds a value. Empty values are nulls.
+func (c *columnProfiler) add(value string) {
+
+	c.rows++
+
+	if value == "" {
+		c.nulls++
+		return
+	}
+
+	c.distinct.add(value)
+
+	// Track the minimum and maximum of numeric and date or time values.
+	if key, ok := c.orderKey(value); ok {
+
+		if c.min == "" || key < c.minKey {
+			c.min, c.minKey = value, key
+		}
+
+		if c.max == "" || key > c.maxKey {
+			c.max, c.maxKey = value, key
+		}
+	}
+
+	c.top.add(value)
+}
+
+// orderKey returns a number ordering the value for the minimum and maximum,
+// if the column is numeric or a date or time.
+func (c *columnProfiler) orderKey(value string) (float64, bool) {
+
+	switch tableSchemaTypes[c.typ] {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n, true
+		}
+	case "date", "datetime", "time":
+		for _, layout := range scanTimeLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return float64(t.UnixNano()), true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// profile returns the accumulated profile.
+func (c *columnProfiler) profile() *ColumnProfile {
+
+	var profile = &ColumnProfile{
+		Name:      c.name,
+		Type:      c.typ,
+		Nulls:     c.nulls,
+		Distinct:  c.distinct.estimate(),
+		Min:       c.min,
+		Max:       c.max,
+		TopValues: make([]*ValueCount, 0),
+	}
+
+	if c.rows > 0 {
+		profile.NullRate = float64(c.nulls) / float64(c.rows)
+	}
+
+	for _, candidate := range c.top.candidates {
+		profile.TopValues = append(profile.TopValues, &ValueCount{candidate.value, candidate.count})
+	}
+
+	sort.Slice(profile.TopValues, func(i, j int) bool {
+
+		if profile.TopValues[i].Count != profile.TopValues[j].Count {
+			return profile.TopValues[i].Count > profile.TopValues[j].Count
+		}
+
+		return profile.TopValues[i].Value < profile.TopValues[j].Value
+	})
+
+	if len(profile.TopValues) > profileTopValues {
+		profile.TopValues = profile.TopValues[:profileTopValues]
+	}
+
+	return profile
+}
+
+// topValues tracks the most frequent values with the space-saving algorithm:
+// a value that is not a candidate replaces the least frequent candidate when
+// there is no room, taking its count plus one. The candidates are a min-heap
+// by count, indexed by value.
+type topValues struct {
+	candidates []*valueCandidate
+	index      map[string]*valueCandidate
+}
+
+// valueCandidate is a candidate value, its count and its position in the
+// heap.
+type valueCandidate struct {
+	value string
+	count int64
+	pos   int
+}
+
+// add counts a value.
+func (t *topValues) add(value string) {
+
+	if candidate, ok := t.index[value]; ok {
+		candidate.count++
+		heap.Fix(t, candidate.pos)
+		return
+	}
+
+	if len(t.candidates) < profileCandidates {
+		candidate := &valueCandidate{value: value, count: 1}
+		t.index[value] = candidate
+		heap.Push(t, candidate)
+		return
+	}
+
+	candidate := t.candidates[0]
+
+	delete(t.index, candidate.value)
+
+	candidate.value = value
+	candidate.count++
+	t.index[value] = candidate
+
+	heap.Fix(t, 0)
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface. Candidates with the
+// same count are ordered by value, so the least value is replaced first.
+func (t *topValues) Len() int { return len(t.candidates) }
+
+func (t *topValues) Less(i, j int) bool {
+
+	if t.candidates[i].count != t.candidates[j].count {
+		return t.candidates[i].count < t.candidates[j].count
+	}
+
+	return t.candidates[i].value < t.candidates[j].value
+}
+
+func (t *topValues) Swap(i, j int) {
+	t.candidates[i], t.candidates[j] = t.candidates[j], t.candidates[i]
+	t.candidates[i].pos = i
+	t.candidates[j].pos = j
+}
+
+func (t *topValues) Push(x interface{}) {
+	candidate := x.(*valueCandidate)
+	candidate.pos = len(t.candidates)
+	t.candidates = append(t.candidates, candidate)
+}
+
+func (t *topValues) Pop() interface{} {
+	candidate := t.candidates[len(t.candidates)-1]
+	t.candidates = t.candidates[:len(t.candidates)-1]
+	return candidate
+}
+
+// hyperLogLog estimates the number of distinct values added to it in a
+// fixed amount of memory.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{
+		registers: make([]uint8, 1<<hllBits),
+	}
+}
+
+// add adds a value.
+func (h *hyperLogLog) add(value string) {
+
+	var (
+		hash = fnv.New64a()
+		x    uint64
+	)
+
+	hash.Write([]byte(value))
+
+	// Mix the bits, since fnv alone spreads short values poorly.
+	x = hash.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	index := x >> (64 - hllBits)
+	rank := uint8(bits.LeadingZeros64(x<<hllBits|1<<(hllBits-1)) + 1)
+
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// estimate returns the estimated number of distinct values, using linear
+// counting for small numbers of values.
+func (h *hyperLogLog) estimate() int64 {
+
+	var (
+		m     = float64(len(h.registers))
+		sum   float64
+		zeros float64
+	)
+
+	for _, register := range h.registers {
+
+		sum += 1 / float64(uint64(1)<<register)
+
+		if register == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/zeros)
+	}
+
+	return int64(math.Round(estimate))
+}
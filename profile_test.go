@@ -0,0 +1,180 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestProfile(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		report  *datadirectory.ProfileReport
+		columns map[string]*datadirectory.ColumnProfile
+		b       bytes.Buffer
+		decoded map[string]interface{}
+		err     error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err = d.Profile(); err != nil {
+		t.Fatalf("Profile(): error in basic function: %s", err)
+	}
+
+	if len(report.Tables) != 3 {
+		t.Fatalf("Profile(): expected 3 tables, got %d", len(report.Tables))
+	}
+
+	if report.Tables["care_site"].Rows != 3 {
+		t.Errorf("Profile(): expected 3 care_site rows, got %d", report.Tables["care_site"].Rows)
+	}
+
+	columns = make(map[string]*datadirectory.ColumnProfile)
+
+	for _, column := range report.Tables["care_site"].Columns {
+		columns[column.Name] = column
+	}
+
+	if column := columns["care_site_id"]; column.Distinct != 3 || column.Min != "1" || column.Max != "3" || column.Nulls != 0 {
+		t.Errorf("Profile(): unexpected care_site_id profile %+v", column)
+	}
+
+	if column := columns["location_id"]; column.Distinct != 1 || len(column.TopValues) != 1 || column.TopValues[0].Count != 3 {
+		t.Errorf("Profile(): unexpected location_id profile %+v", column)
+	}
+
+	if column := columns["specialty_concept_id"]; column.Nulls != 1 || column.NullRate < 0.33 || column.NullRate > 0.34 {
+		t.Errorf("Profile(): unexpected specialty_concept_id null rate %f", column.NullRate)
+	}
+
+	if columns["care_site_name"].Min != "" {
+		t.Errorf("Profile(): min set for string column")
+	}
+
+	if err = report.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON(): error in basic function: %s", err)
+	}
+
+	if err = json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Errorf("WriteJSON(): invalid JSON: %s", err)
+	}
+
+	b.Reset()
+
+	if err = report.WriteMarkdown(&b); err != nil {
+		t.Fatalf("WriteMarkdown(): error in basic function: %s", err)
+	}
+
+	if !strings.Contains(b.String(), "## care_site\n") || !strings.Contains(b.String(), "| care_site_id | integer | 0.0% | 3 | 1 | 3 |") {
+		t.Errorf("WriteMarkdown(): unexpected output:\n%s", b.String())
+	}
+}
+
+func TestProfileChecksumMismatch(t *testing.T) {
+
+	var (
+		cfg *datadirectory.Config
+		d   *datadirectory.DataDirectory
+		dir string
+		err error
+	)
+
+	dir = copyTestData(t)
+
+	if err = os.WriteFile(filepath.Join(dir, "location.csv"), []byte("location_id\n\"1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = d.Profile(); err == nil {
+		t.Errorf("Profile(): no error thrown for checksum mismatch")
+	}
+}
+
+func TestProfileDuplicateColumns(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		dir    string
+		data   strings.Builder
+		report *datadirectory.ProfileReport
+		err    error
+	)
+
+	dir = copyTestData(t)
+
+	// A frequent value followed by more distinct values than are tracked, in
+	// two columns with the same name.
+	data.WriteString("provider_id,PROVIDER_ID\n")
+
+	for i := 0; i < 50; i++ {
+		data.WriteString("\"1\",\"1\"\n")
+	}
+
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&data, "\"x%d\",\"x%d\"\n", i, i)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), []byte(data.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("provider.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err = d.Profile(); err != nil {
+		t.Fatalf("Profile(): error in basic function: %s", err)
+	}
+
+	columns := report.Tables["provider"].Columns
+
+	if len(columns) != 1 {
+		t.Fatalf("Profile(): expected 1 provider column, got %d", len(columns))
+	}
+
+	if top := columns[0].TopValues[0]; top.Value != "1" || top.Count != 50 {
+		t.Errorf("Profile(): expected top value '1' (50), got '%s' (%d)", top.Value, top.Count)
+	}
+
+	if columns[0].Nulls != 0 || columns[0].NullRate != 0 {
+		t.Errorf("Profile(): expected no nulls, got %d", columns[0].Nulls)
+	}
+}
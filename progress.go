@@ -0,0 +1,162 @@
+package datadirectory
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Minimum time between progress reports while a file is being hashed.
+const progressInterval = 100 * time.Millisecond
+
+// Progress is a report on the progress of a long running operation, such as
+// ValidateContext or PopulateContext. File is the data file currently being
+// hashed, relative to the data directory. ETA is zero until it can be
+// estimated.
+type Progress struct {
+	File        string
+	BytesHashed int64
+	TotalBytes  int64
+	Elapsed     time.Duration
+	ETA         time.Duration
+}
+
+// ProgressReporter receives progress reports. ReportProgress is called when
+// each file is started and finished and periodically in between.
+type ProgressReporter interface {
+	ReportProgress(p Progress)
+}
+
+// ProgressFunc is an adapter allowing an ordinary function to be used as a
+// ProgressReporter.
+type ProgressFunc func(p Progress)
+
+// ReportProgress calls f(p).
+func (f ProgressFunc) ReportProgress(p Progress) {
+	f(p)
+}
+
+// progressTracker counts the bytes hashed by an operation, reporting them to
+// a ProgressReporter and stopping reads when its context is done. A nil
+// tracker does neither.
+type progressTracker struct {
+	ctx      context.Context
+	reporter ProgressReporter
+	file     string
+	done     int64
+	total    int64
+	start    time.Time
+	last     time.Time
+}
+
+// newProgressTracker returns a tracker for an operation that will hash total
+// bytes. reporter may be nil.
+func newProgressTracker(ctx context.Context, reporter ProgressReporter, total int64) *progressTracker {
+	return &progressTracker{
+		ctx:      ctx,
+		reporter: reporter,
+		total:    total,
+		start:    time.Now(),
+	}
+}
+
+// err returns the error of the tracker context, if it is done.
+func (t *progressTracker) err() error {
+
+	if t == nil {
+		return nil
+	}
+
+	return t.ctx.Err()
+}
+
+// startFile reports the start of hashing the named file.
+func (t *progressTracker) startFile(file string) {
+
+	if t == nil {
+		return
+	}
+
+	t.file = file
+	t.report()
+}
+
+// finishFile reports the end of hashing the current file.
+func (t *progressTracker) finishFile() {
+
+	if t == nil {
+		return
+	}
+
+	t.report()
+}
+
+// reader returns r wrapped to count the bytes read from it and to fail once
+// the tracker context is done.
+func (t *progressTracker) reader(r io.Reader) io.Reader {
+
+	if t == nil {
+		return r
+	}
+
+	return &progressReader{r: r, t: t}
+}
+
+// add counts n bytes hashed, reporting progress if it has not been reported
+// recently.
+func (t *progressTracker) add(n int64) {
+
+	if t == nil {
+		return
+	}
+
+	t.done += n
+
+	if time.Since(t.last) >= progressInterval {
+		t.report()
+	}
+}
+
+// report sends the current progress to the reporter.
+func (t *progressTracker) report() {
+
+	var p Progress
+
+	if t.reporter == nil {
+		return
+	}
+
+	t.last = time.Now()
+
+	p = Progress{
+		File:        t.file,
+		BytesHashed: t.done,
+		TotalBytes:  t.total,
+		Elapsed:     t.last.Sub(t.start),
+	}
+
+	// Estimate the remaining time from the average rate so far.
+	if t.done > 0 && t.total > t.done {
+		p.ETA = time.Duration(float64(p.Elapsed) * float64(t.total-t.done) / float64(t.done))
+	}
+
+	t.reporter.ReportProgress(p)
+}
+
+// progressReader is a reader counting the bytes read for a progressTracker.
+type progressReader struct {
+	r io.Reader
+	t *progressTracker
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+
+	if err := r.t.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.r.Read(p)
+	r.t.add(int64(n))
+
+	return n, err
+}
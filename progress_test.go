@@ -0,0 +1,148 @@
+package datadirectory_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestValidateContextProgress(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		dir     string
+		reports []datadirectory.Progress
+		files   map[string]bool
+		total   int64
+		last    datadirectory.Progress
+		err     error
+	)
+
+	dir = copyTestData(t)
+
+	for _, name := range []string{"location.csv", "care_site.csv", "provider.csv"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += fi.Size()
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+		Progress: datadirectory.ProgressFunc(func(p datadirectory.Progress) {
+			reports = append(reports, p)
+		}),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.ValidateContext(context.Background()); err != nil {
+		t.Fatalf("ValidateContext(): error in basic function: %s", err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatalf("ValidateContext(): no progress reported")
+	}
+
+	files = make(map[string]bool)
+
+	for _, report := range reports {
+		files[report.File] = true
+	}
+
+	if len(files) != 3 {
+		t.Errorf("ValidateContext(): expected progress for 3 files, got %v", files)
+	}
+
+	last = reports[len(reports)-1]
+
+	if last.TotalBytes != total || last.BytesHashed != total {
+		t.Errorf("ValidateContext(): expected final progress of %d of %d bytes, got %d of %d", total, total, last.BytesHashed, last.TotalBytes)
+	}
+
+	if last.ETA != 0 {
+		t.Errorf("ValidateContext(): expected no ETA when complete, got %s", last.ETA)
+	}
+}
+
+func TestValidateContextCanceled(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		ctx    context.Context
+		cancel context.CancelFunc
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if err = d.ValidateContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ValidateContext(): expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPopulateContextCanceled(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		ctx    context.Context
+		cancel context.CancelFunc
+		dir    string
+		err    error
+	)
+
+	dir = copyTestData(t)
+
+	if err = os.Remove(filepath.Join(dir, "metadata.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	ctx, cancel = context.WithCancel(context.Background())
+
+	// Cancel once the first file has been started.
+	d.Progress = datadirectory.ProgressFunc(func(p datadirectory.Progress) {
+		cancel()
+	})
+
+	if err = d.PopulateContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("PopulateContext(): expected context.Canceled, got %v", err)
+	}
+
+	if len(d.RecordMaps) != 0 {
+		t.Errorf("PopulateContext(): expected no records after cancellation, got %d", len(d.RecordMaps))
+	}
+}
@@ -1,31 +1,30 @@
 package datadirectory
 
 import (
-	"encoding/csv"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"strconv"
 	"strings"
 )
 
-// ReadMetadataFromFile reads data from an existing metadata.csv file into
-// the appropriate attributes.
+// ReadMetadataFromFile reads data from an existing metadata file into the
+// appropriate attributes. The format is chosen by the FilePath extension,
+// defaulting to csv.
 func (d *DataDirectory) ReadMetadataFromFile() error {
 
 	var (
-		file *os.File
+		file fs.File
 		err  error
 	)
 
-	// Create a strict csv.Reader
-	if file, err = os.Open(d.FilePath); err != nil {
+	if file, err = d.openMetadataFile(); err != nil {
 		return err
 	}
 
 	defer file.Close()
 
-	if err = d.ReadMetadata(file); err != nil {
+	if err = d.ReadMetadataFormat(file, manifestFormatFromPath(d.FilePath)); err != nil {
 		return err
 	}
 
@@ -36,37 +35,36 @@ func (d *DataDirectory) ReadMetadataFromFile() error {
 // ReadMetadata reads metadata.csv-style data from the passed reader
 // into the appropriate attributes.
 func (d *DataDirectory) ReadMetadata(r io.Reader) error {
+	return d.ReadMetadataFormat(r, CSVFormat)
+}
+
+// ReadMetadataFormat reads metadata in the passed format from the passed
+// reader into the appropriate attributes.
+func (d *DataDirectory) ReadMetadataFormat(r io.Reader, format ManifestFormat) error {
 
 	var (
-		csvReader *csv.Reader
-		line      int
-		err       error
+		codec manifestCodec
+		rows  [][]string
+		line  int
+		err   error
 	)
 
-	csvReader = csv.NewReader(r)
-	csvReader.LazyQuotes = false
-	csvReader.TrimLeadingSpace = false
+	if codec, err = manifestCodecFor(format); err != nil {
+		return err
+	}
 
-	// Read in the header, standardizing to lowercase and ensuring no
-	// unexpected values are present.
-	if d.header, err = csvReader.Read(); err != nil {
+	// Read in the header and records.
+	if d.header, rows, err = codec.decode(r); err != nil {
 		return err
 	}
 
+	// Standardize the header to lowercase, ensuring no unexpected values are
+	// present.
 	for i, headerVal := range d.header {
 
-		var found bool
-
 		d.header[i] = strings.ToLower(headerVal)
 
-		for _, cHeaderVal := range canonicalHeader {
-			if d.header[i] == cHeaderVal {
-				found = true
-				break
-			}
-		}
-
-		if !found {
+		if _, found := headerReq[d.header[i]]; !found {
 			return fmt.Errorf("unexpected header value: %s", headerVal)
 		}
 	}
@@ -94,21 +92,10 @@ func (d *DataDirectory) ReadMetadata(r io.Reader) error {
 	}
 
 	// Read records into the DataDirectory record maps.
-	for {
+	for _, record := range rows {
 
 		var recordMap map[string]string
 
-		// Get next record, exiting if there's no more.
-		record, err := csvReader.Read()
-
-		if err == io.EOF {
-			break
-		}
-
-		if err != nil {
-			return err
-		}
-
 		line++
 
 		// Create map of header values to record values.
@@ -0,0 +1,195 @@
+package datadirectory
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Record is a typed view of a single metadata record. RowCount and Size are
+// the number of csv rows, not counting the header, and the stored size in
+// bytes of the data file, if recorded. Line is the position of the record in
+// the metadata file. Extra holds any values not covered by the other fields.
+type Record struct {
+	Organization string
+	Filename     string
+	Checksum     string
+	CDM          string
+	CDMVersion   string
+	Table        string
+	ETL          string
+	DataVersion  string
+	Compression  string
+	RowCount     string
+	Size         string
+	Line         int
+	Extra        map[string]string
+}
+
+// newRecord creates a Record from a record map.
+func newRecord(recordMap map[string]string) *Record {
+
+	var record = &Record{
+		Extra: make(map[string]string),
+	}
+
+	for key, val := range recordMap {
+		switch key {
+		case "organization":
+			record.Organization = val
+		case "filename":
+			record.Filename = val
+		case "checksum":
+			record.Checksum = val
+		case "cdm":
+			record.CDM = val
+		case "cdm-version":
+			record.CDMVersion = val
+		case "table":
+			record.Table = val
+		case "etl":
+			record.ETL = val
+		case "data-version":
+			record.DataVersion = val
+		case "compression":
+			record.Compression = val
+		case "row-count":
+			record.RowCount = val
+		case "size":
+			record.Size = val
+		case "line":
+			record.Line, _ = strconv.Atoi(val)
+		default:
+			record.Extra[key] = val
+		}
+	}
+
+	return record
+}
+
+// Map returns the record as a record map, as used in the DataDirectory
+// RecordMaps. Values that are not required are only included when set.
+func (r *Record) Map() map[string]string {
+
+	var recordMap = make(map[string]string)
+
+	for key, val := range r.Extra {
+		recordMap[key] = val
+	}
+
+	for key, val := range map[string]string{
+		"organization": r.Organization,
+		"filename":     r.Filename,
+		"checksum":     r.Checksum,
+		"cdm":          r.CDM,
+		"cdm-version":  r.CDMVersion,
+		"table":        r.Table,
+		"etl":          r.ETL,
+		"data-version": r.DataVersion,
+		"compression":  r.Compression,
+		"row-count":    r.RowCount,
+		"size":         r.Size,
+	} {
+		if headerReq[key] || val != "" {
+			recordMap[key] = val
+		}
+	}
+
+	if r.Line != 0 {
+		recordMap["line"] = strconv.Itoa(r.Line)
+	}
+
+	return recordMap
+}
+
+// Records returns typed copies of the DataDirectory RecordMaps, in order.
+// The RecordMaps remain the authoritative metadata, so changes to the
+// returned records are not reflected in the DataDirectory until they are
+// passed to UpdateRecord.
+func (d *DataDirectory) Records() []*Record {
+
+	var records = make([]*Record, 0, len(d.RecordMaps))
+
+	for _, recordMap := range d.RecordMaps {
+		records = append(records, newRecord(recordMap))
+	}
+
+	return records
+}
+
+// UpdateRecord writes a record, usually one returned by Records, back to the
+// RecordMaps, replacing the record for its Filename. The record Line is set
+// to the current line of that record, which may have changed since the record
+// was read. Extra values are written as they are, so values not covered by
+// the other fields are kept.
+func (d *DataDirectory) UpdateRecord(record *Record) error {
+
+	var (
+		i         int
+		recordMap map[string]string
+	)
+
+	if i = d.recordIndex(record.Filename); i < 0 {
+		return fmt.Errorf("file '%s' not found in metadata", record.Filename)
+	}
+
+	record.Line = i + 2
+	recordMap = record.Map()
+
+	for _, val := range optionalHeader {
+		if recordMap[val] != "" {
+			d.addHeader(val)
+		}
+	}
+
+	d.RecordMaps[i] = recordMap
+
+	return nil
+}
+
+// RecordByFilename returns the record for the passed filename, relative to
+// the data directory, or nil if there is none.
+func (d *DataDirectory) RecordByFilename(filename string) *Record {
+
+	if i := d.recordIndex(filename); i >= 0 {
+		return newRecord(d.RecordMaps[i])
+	}
+
+	return nil
+}
+
+// RecordsByTable returns the records for the passed table, in order. A table
+// may be split across several files.
+func (d *DataDirectory) RecordsByTable(table string) []*Record {
+
+	var records = make([]*Record, 0)
+
+	for _, recordMap := range d.RecordMaps {
+		if recordMap["table"] == table {
+			records = append(records, newRecord(recordMap))
+		}
+	}
+
+	return records
+}
+
+// Tables returns the distinct tables in the metadata, in order of first
+// appearance.
+func (d *DataDirectory) Tables() []string {
+
+	var (
+		tables []string
+		seen   map[string]bool
+	)
+
+	tables = make([]string, 0)
+	seen = make(map[string]bool)
+
+	for _, recordMap := range d.RecordMaps {
+		if !seen[recordMap["table"]] {
+			seen[recordMap["table"]] = true
+			tables = append(tables, recordMap["table"])
+		}
+	}
+
+	return tables
+}
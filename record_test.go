@@ -0,0 +1,129 @@
+package datadirectory_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+const recordMetadata = "organization,filename,checksum,cdm,cdm-version,table,etl\n" +
+	"foo,person_1.csv,123,pedsnet,2.1.0,person,http://foo.org/etl\n" +
+	"foo,location.csv,456,pedsnet,2.1.0,location,http://foo.org/etl\n" +
+	"foo,person_2.csv,789,pedsnet,2.1.0,person,http://foo.org/etl\n"
+
+func TestRecordByFilename(t *testing.T) {
+
+	var (
+		d      *datadirectory.DataDirectory
+		record *datadirectory.Record
+		err    error
+	)
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(strings.NewReader(recordMetadata)); err != nil {
+		t.Fatal(err)
+	}
+
+	if record = d.RecordByFilename("./location.csv"); record == nil {
+		t.Fatalf("RecordByFilename(): record for 'location.csv' not found")
+	}
+
+	if record.Checksum != "456" || record.CDMVersion != "2.1.0" || record.Line != 3 {
+		t.Errorf("RecordByFilename(): unexpected record values: %+v", record)
+	}
+
+	if !reflect.DeepEqual(record.Map(), d.RecordMaps[1]) {
+		t.Errorf("Map(): expected record map (%v) does not match actual record map (%v)", d.RecordMaps[1], record.Map())
+	}
+
+	if d.RecordByFilename("provider.csv") != nil {
+		t.Errorf("RecordByFilename(): record returned for unlisted file")
+	}
+
+}
+
+func TestRecordsByTable(t *testing.T) {
+
+	var (
+		d       *datadirectory.DataDirectory
+		records []*datadirectory.Record
+		err     error
+	)
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(strings.NewReader(recordMetadata)); err != nil {
+		t.Fatal(err)
+	}
+
+	records = d.RecordsByTable("person")
+
+	if len(records) != 2 || records[0].Filename != "person_1.csv" || records[1].Filename != "person_2.csv" {
+		t.Errorf("RecordsByTable(): unexpected records for 'person': %+v", records)
+	}
+
+	if !reflect.DeepEqual(d.Tables(), []string{"person", "location"}) {
+		t.Errorf("Tables(): expected tables ([person location]) do not match actual tables (%v)", d.Tables())
+	}
+
+}
+
+func TestUpdateRecord(t *testing.T) {
+
+	var (
+		d      *datadirectory.DataDirectory
+		record *datadirectory.Record
+		err    error
+	)
+
+	d = &datadirectory.DataDirectory{}
+
+	if err = d.ReadMetadata(strings.NewReader(recordMetadata)); err != nil {
+		t.Fatal(err)
+	}
+
+	record = d.RecordByFilename("location.csv")
+	record.Checksum = "abc"
+	record.Extra["notes"] = "kept"
+
+	if err = d.UpdateRecord(record); err != nil {
+		t.Fatalf("UpdateRecord(): error in basic function: %s", err)
+	}
+
+	if d.RecordMaps[1]["checksum"] != "abc" || d.RecordMaps[1]["notes"] != "kept" || d.RecordMaps[1]["line"] != "3" {
+		t.Errorf("UpdateRecord(): record not written back: %v", d.RecordMaps[1])
+	}
+
+	if d.RecordByFilename("location.csv").Checksum != "abc" {
+		t.Errorf("UpdateRecord(): updated record not returned by RecordByFilename")
+	}
+
+	// A record read before an earlier record is removed still updates its
+	// own file.
+	if err = d.RemoveFile("person_1.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	record.Checksum = "def"
+
+	if err = d.UpdateRecord(record); err != nil {
+		t.Fatalf("UpdateRecord(): error updating a record after RemoveFile: %s", err)
+	}
+
+	if d.RecordMaps[0]["filename"] != "location.csv" || d.RecordMaps[0]["checksum"] != "def" || d.RecordMaps[0]["line"] != "2" {
+		t.Errorf("UpdateRecord(): stale record not written to its own file: %v", d.RecordMaps[0])
+	}
+
+	if d.RecordMaps[1]["filename"] != "person_2.csv" || d.RecordMaps[1]["checksum"] != "789" {
+		t.Errorf("UpdateRecord(): stale record overwrote another file: %v", d.RecordMaps[1])
+	}
+
+	record.Filename = "person_1.csv"
+
+	if err = d.UpdateRecord(record); err == nil {
+		t.Errorf("UpdateRecord(): expected error for a file not in the metadata")
+	}
+}
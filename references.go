@@ -0,0 +1,338 @@
+package datadirectory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Maximum number of example rows kept for each foreign key violation.
+const maxOrphanExamples = 5
+
+// ForeignKey is a reference from the Fields of Table to the ReferencesFields
+// of ReferencesTable, as defined by the data models service or in a local
+// config read by ReadForeignKeys.
+type ForeignKey struct {
+	Table            string   `json:"table"`
+	Fields           []string `json:"fields"`
+	ReferencesTable  string   `json:"references_table"`
+	ReferencesFields []string `json:"references_fields"`
+}
+
+// ReferenceViolation reports the rows of a table whose foreign key values are
+// not found in the referenced table. Count is the number of orphan rows, of
+// which at most a few are kept in Examples.
+type ReferenceViolation struct {
+	ForeignKey *ForeignKey
+	Count      int64
+	Examples   []*OrphanRow
+}
+
+// OrphanRow is a row with foreign key values that are not found in the
+// referenced table. Values are the foreign key values, in field order.
+type OrphanRow struct {
+	Filename string
+	Line     int
+	Values   []string
+}
+
+// ReadForeignKeys reads foreign key definitions from a JSON array of objects
+// with "table", "fields", "references_table" and "references_fields"
+// properties, for use as the DataDirectory ForeignKeys.
+func ReadForeignKeys(r io.Reader) ([]*ForeignKey, error) {
+
+	var (
+		keys []*ForeignKey
+		err  error
+	)
+
+	if err = json.NewDecoder(r).Decode(&keys); err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		if key.Table == "" || key.ReferencesTable == "" || len(key.Fields) == 0 || len(key.Fields) != len(key.ReferencesFields) {
+			return nil, fmt.Errorf("foreign key %d must have a table, a references table and matching fields", i+1)
+		}
+	}
+
+	return keys, nil
+}
+
+// ValidateReferences checks the foreign keys of the tables listed in the
+// metadata, those defined by the data models service and the DataDirectory
+// ForeignKeys, and returns a violation for each foreign key with orphan
+// values. Rows with an empty value in any foreign key field are not checked.
+// Foreign keys referencing tables not listed in the metadata are skipped.
+// Each table is read at most twice: once to collect its keys and once to
+// check its references.
+func (d *DataDirectory) ValidateReferences() ([]*ReferenceViolation, error) {
+
+	var (
+		keys       []*ForeignKey
+		keySets    map[string]map[string]bool
+		refFields  map[string][][]string
+		tables     []string
+		byTable    map[string][]*ForeignKey
+		violations []*ReferenceViolation
+		err        error
+	)
+
+	keys = d.foreignKeys()
+	keySets = make(map[string]map[string]bool)
+	refFields = make(map[string][][]string)
+	byTable = make(map[string][]*ForeignKey)
+
+	for _, key := range keys {
+
+		if byTable[key.Table] == nil {
+			tables = append(tables, key.Table)
+		}
+
+		byTable[key.Table] = append(byTable[key.Table], key)
+
+		if keySets[keySetName(key.ReferencesTable, key.ReferencesFields)] == nil {
+			keySets[keySetName(key.ReferencesTable, key.ReferencesFields)] = make(map[string]bool)
+			refFields[key.ReferencesTable] = append(refFields[key.ReferencesTable], key.ReferencesFields)
+		}
+	}
+
+	// Collect the referenced key values, reading each referenced table once.
+	for table, fieldLists := range refFields {
+		if err = d.readKeySets(table, fieldLists, keySets); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the foreign key values, reading each referencing table once.
+	for _, table := range tables {
+
+		var tableViolations []*ReferenceViolation
+
+		if tableViolations, err = d.checkReferences(table, byTable[table], keySets); err != nil {
+			return nil, err
+		}
+
+		violations = append(violations, tableViolations...)
+	}
+
+	return violations, nil
+}
+
+// foreignKeys returns the foreign keys between tables listed in the metadata,
+// from the data models service definitions of the tables followed by the
+// DataDirectory ForeignKeys. Keys with the same table, fields, referenced
+// table and referenced fields are returned once.
+func (d *DataDirectory) foreignKeys() []*ForeignKey {
+
+	var (
+		listed     map[string]bool
+		seen       map[string]bool
+		keys       []*ForeignKey
+		listedKeys []*ForeignKey
+	)
+
+	listed = make(map[string]bool)
+	seen = make(map[string]bool)
+
+	for _, table := range d.Tables() {
+		listed[table] = true
+	}
+
+	for _, table := range d.Tables() {
+
+		record := d.RecordsByTable(table)[0]
+
+		if modelDef := d.modelTable(record.CDM, record.CDMVersion, table); modelDef != nil {
+			keys = append(keys, modelDef.ForeignKeys...)
+		}
+	}
+
+	keys = append(keys, d.ForeignKeys...)
+
+	// Keep only references between listed tables, once each.
+	for _, key := range keys {
+
+		name := strings.ToLower(keySetName(key.Table, key.Fields) + " " + keySetName(key.ReferencesTable, key.ReferencesFields))
+
+		if listed[key.Table] && listed[key.ReferencesTable] && !seen[name] {
+			seen[name] = true
+			listedKeys = append(listedKeys, key)
+		}
+	}
+
+	return listedKeys
+}
+
+// readKeySets adds the values of each list of fields in the passed table to
+// the key sets, named by keySetName.
+func (d *DataDirectory) readKeySets(table string, fieldLists [][]string, keySets map[string]map[string]bool) error {
+
+	var (
+		reader  *TableReader
+		sets    []map[string]bool
+		columns [][]int
+		err     error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	for _, fields := range fieldLists {
+		sets = append(sets, keySets[keySetName(table, fields)])
+	}
+
+	for reader.Next() {
+
+		if reader.FileChanged() {
+
+			columns = make([][]int, len(fieldLists))
+
+			for i, fields := range fieldLists {
+				if columns[i], err = reader.Columns(fields); err != nil {
+					return err
+				}
+			}
+		}
+
+		for i := range fieldLists {
+			if value, ok := keyValue(reader.Values(), columns[i]); ok {
+				sets[i][value] = true
+			}
+		}
+	}
+
+	return reader.Err()
+}
+
+// checkReferences checks the passed foreign keys of a table against the key
+// sets, returning a violation for each foreign key with orphan values.
+func (d *DataDirectory) checkReferences(table string, keys []*ForeignKey, keySets map[string]map[string]bool) ([]*ReferenceViolation, error) {
+
+	var (
+		reader     *TableReader
+		sets       []map[string]bool
+		columns    [][]int
+		found      []*ReferenceViolation
+		violations []*ReferenceViolation
+		err        error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	for _, key := range keys {
+		sets = append(sets, keySets[keySetName(key.ReferencesTable, key.ReferencesFields)])
+	}
+
+	found = make([]*ReferenceViolation, len(keys))
+
+	for reader.Next() {
+
+		// Foreign key fields missing from a file have no values to check.
+		if reader.FileChanged() {
+
+			columns = make([][]int, len(keys))
+
+			for i, key := range keys {
+				columns[i], _ = reader.Columns(key.Fields)
+			}
+		}
+
+		for i, key := range keys {
+
+			value, ok := keyValue(reader.Values(), columns[i])
+
+			if !ok || sets[i][value] {
+				continue
+			}
+
+			if found[i] == nil {
+				found[i] = &ReferenceViolation{ForeignKey: key}
+			}
+
+			found[i].Count++
+
+			if len(found[i].Examples) < maxOrphanExamples {
+				found[i].Examples = append(found[i].Examples, &OrphanRow{
+					Filename: reader.Filename(),
+					Line:     reader.Line(),
+					Values:   strings.Split(value, "\x00"),
+				})
+			}
+		}
+	}
+
+	if err = reader.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, violation := range found {
+		if violation != nil {
+			violations = append(violations, violation)
+		}
+	}
+
+	return violations, nil
+}
+
+// keySetName returns the name of the key set for the passed fields of a
+// table.
+func keySetName(table string, fields []string) string {
+	return table + "(" + strings.Join(fields, ",") + ")"
+}
+
+// fieldColumns returns the header columns of the passed fields, matched
+// without regard to case.
+func fieldColumns(header []string, fields []string) ([]int, error) {
+
+	var columns = make([]int, 0, len(fields))
+
+	for _, field := range fields {
+
+		found := false
+
+		for i, column := range header {
+			if strings.EqualFold(column, field) {
+				columns = append(columns, i)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("column '%s' not found", field)
+		}
+	}
+
+	return columns, nil
+}
+
+// keyValue returns the values of the passed columns joined into a single key.
+// ok is false if the columns are missing or any value is empty.
+func keyValue(row []string, columns []int) (string, bool) {
+
+	var values = make([]string, 0, len(columns))
+
+	if columns == nil {
+		return "", false
+	}
+
+	for _, column := range columns {
+
+		if column >= len(row) || row[column] == "" {
+			return "", false
+		}
+
+		values = append(values, row[column])
+	}
+
+	return strings.Join(values, "\x00"), true
+}
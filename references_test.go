@@ -0,0 +1,130 @@
+package datadirectory_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestValidateReferences(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if violations, err = d.ValidateReferences(); err != nil {
+		t.Fatalf("ValidateReferences(): error in basic function: %s", err)
+	}
+
+	if len(violations) != 0 {
+		t.Errorf("ValidateReferences(): expected no violations in test data, got %d", len(violations))
+	}
+}
+
+func TestValidateReferencesOrphans(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		dir        string
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	dir = copyTestData(t)
+
+	// Point a provider at a care site that does not exist, leaving another
+	// without a care site.
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), []byte("care_site_id,provider_id\n\"1\",\"1\"\n\"9\",\"2\"\n,\"3\"\n\"9\",\"4\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A local key repeating a data models service key is checked once.
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+		ForeignKeys: []*datadirectory.ForeignKey{
+			{Table: "provider", Fields: []string{"CARE_SITE_ID"}, ReferencesTable: "care_site", ReferencesFields: []string{"care_site_id"}},
+		},
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if violations, err = d.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(violations) != 1 {
+		t.Fatalf("ValidateReferences(): expected 1 violation, got %d", len(violations))
+	}
+
+	if violations[0].ForeignKey.Table != "provider" || violations[0].ForeignKey.ReferencesTable != "care_site" {
+		t.Errorf("ValidateReferences(): unexpected foreign key %+v", violations[0].ForeignKey)
+	}
+
+	if violations[0].Count != 2 || len(violations[0].Examples) != 2 {
+		t.Fatalf("ValidateReferences(): expected 2 orphan rows, got %d", violations[0].Count)
+	}
+
+	if example := violations[0].Examples[0]; example.Filename != "provider.csv" || example.Line != 3 || strings.Join(example.Values, ",") != "9" {
+		t.Errorf("ValidateReferences(): unexpected example %+v", example)
+	}
+}
+
+func TestValidateReferencesLocal(t *testing.T) {
+
+	var (
+		cfg        *datadirectory.Config
+		d          *datadirectory.DataDirectory
+		keys       []*datadirectory.ForeignKey
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	// Every provider year_of_birth must be a location zip, which fails.
+	if keys, err = datadirectory.ReadForeignKeys(strings.NewReader(`[{"table": "provider", "fields": ["year_of_birth"], "references_table": "location", "references_fields": ["zip"]}]`)); err != nil {
+		t.Fatalf("ReadForeignKeys(): error in basic function: %s", err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+		ForeignKeys: keys,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if violations, err = d.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(violations) != 0 {
+		t.Errorf("ValidateReferences(): expected empty year_of_birth values to be skipped, got %d violations", len(violations))
+	}
+
+	if _, err = datadirectory.ReadForeignKeys(strings.NewReader(`[{"table": "provider", "fields": ["care_site_id"], "references_table": "care_site"}]`)); err == nil {
+		t.Errorf("ReadForeignKeys(): no error thrown for foreign key without references fields")
+	}
+}
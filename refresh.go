@@ -0,0 +1,179 @@
+package datadirectory
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RefreshSummary lists the data files affected by a Refresh, by path relative
+// to the data directory.
+type RefreshSummary struct {
+	Added     []string
+	Changed   []string
+	Removed   []string
+	Unchanged []string
+}
+
+// Refresh updates the existing DataDirectory metadata, usually read with
+// ReadMetadataFromFile, to match the data files currently in the directory.
+// Records for files that no longer exist are dropped and records for new
+// files are added. Checksums are only recalculated for files modified since
+// the metadata file was last written or whose size differs from the recorded
+// size; the table and etl values of existing records are kept as they are.
+// Since this relies on modification times, a file replaced by one of the same
+// size with an older modification time, as copied with preserved times, is
+// not noticed; PopulateMetadataFromData recalculates every checksum.
+func (d *DataDirectory) Refresh() (*RefreshSummary, error) {
+
+	var (
+		summary    *RefreshSummary
+		metaTime   time.Time
+		metaInfo   fs.FileInfo
+		existing   map[string]map[string]string
+		seen       map[string]bool
+		added      []map[string]string
+		recordMaps []map[string]string
+		err        error
+	)
+
+	summary = &RefreshSummary{}
+
+	// Files modified after the metadata file are considered changed. Without
+	// a metadata file, every existing record is rechecked.
+	if metaInfo, err = d.statMetadataFile(); err == nil {
+		metaTime = metaInfo.ModTime()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// Default any missing DataDirectory attributes from the existing records
+	// so that new records match them.
+	if len(d.RecordMaps) > 0 {
+
+		first := d.RecordMaps[0]
+
+		if d.Site == "" {
+			d.Site = first["organization"]
+		}
+
+		if d.Model == "" {
+			d.Model = first["cdm"]
+		}
+
+		if d.ModelVersion == "" {
+			d.ModelVersion = first["cdm-version"]
+		}
+
+		if d.Etl == "" {
+			d.Etl = first["etl"]
+		}
+
+		if d.DataVersion == "" {
+			d.DataVersion = first["data-version"]
+		}
+	}
+
+	existing = recordsByFilename(d.RecordMaps)
+	seen = make(map[string]bool)
+	recordMaps = make([]map[string]string, 0)
+
+	// Walk the data files, updating or creating their records.
+	err = fs.WalkDir(d.fsys(), ".", func(path string, entry fs.DirEntry, inErr error) error {
+
+		var (
+			relPath     string
+			table       string
+			compression string
+			fi          fs.FileInfo
+			fh          *fileHash
+			start       time.Time
+			recordMap   map[string]string
+			ok          bool
+			err         error
+		)
+
+		if err = inErr; err != nil {
+			return err
+		}
+
+		relPath = filepath.FromSlash(path)
+
+		if !d.isDataFile(relPath, entry.IsDir()) {
+			return nil
+		}
+
+		if fi, err = entry.Info(); err != nil {
+			return err
+		}
+
+		seen[relPath] = true
+
+		// Existing, unmodified file.
+		if recordMap, ok = existing[relPath]; ok && !fi.ModTime().After(metaTime) && (recordMap["size"] == "" || recordMap["size"] == strconv.FormatInt(fi.Size(), 10)) {
+			summary.Unchanged = append(summary.Unchanged, relPath)
+			return nil
+		}
+
+		start = time.Now()
+
+		if _, compression, _ = splitDataFileName(relPath); ok {
+			compression = recordCompression(recordMap)
+		}
+
+		if fh, err = d.hashFile(relPath, compression, nil); err != nil {
+			return err
+		}
+
+		d.logger().Info("calculated checksum", "file", relPath, "table", recordMap["table"], "bytes", fh.size, "duration", time.Since(start))
+
+		// Existing, possibly modified file.
+		if ok {
+
+			if recordMap["checksum"] == fh.checksum {
+				summary.Unchanged = append(summary.Unchanged, relPath)
+			} else {
+				summary.Changed = append(summary.Changed, relPath)
+			}
+
+			d.setFileHash(recordMap, fh)
+
+			return nil
+		}
+
+		// New file.
+		if table, err = d.tableForFile(relPath); err != nil {
+			return err
+		}
+
+		added = append(added, d.newRecordMap(relPath, fh, table))
+		summary.Added = append(summary.Added, relPath)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Rebuild the records, keeping the existing order, dropping records for
+	// missing files and appending new ones.
+	for _, recordMap := range d.RecordMaps {
+
+		filename := filepath.Clean(recordMap["filename"])
+
+		if !seen[filename] {
+			summary.Removed = append(summary.Removed, recordMap["filename"])
+			continue
+		}
+
+		recordMaps = append(recordMaps, recordMap)
+	}
+
+	d.RecordMaps = append(recordMaps, added...)
+	d.renumberRecords()
+
+	return summary, nil
+}
@@ -0,0 +1,155 @@
+package datadirectory_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestRefresh(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		dir     string
+		past    time.Time
+		future  time.Time
+		summary *datadirectory.RefreshSummary
+		err     error
+	)
+
+	dir = copyTestData(t)
+
+	// Make every data file older than the metadata file.
+	past = time.Now().Add(-time.Hour)
+	future = time.Now().Add(time.Hour)
+
+	for _, name := range []string{"location.csv", "care_site.csv", "provider.csv"} {
+		if err = os.Chtimes(filepath.Join(dir, name), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Change one file, remove one and add one.
+	if err = os.WriteFile(filepath.Join(dir, "location.csv"), []byte("location_id\n\"1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chtimes(filepath.Join(dir, "location.csv"), future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(filepath.Join(dir, "provider.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "person.csv"), []byte("person_id\n\"1\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error in basic function: %s", err)
+	}
+
+	if summary, err = d.Refresh(); err != nil {
+		t.Fatalf("Refresh(): error in basic function: %s", err)
+	}
+
+	if !reflect.DeepEqual(summary.Added, []string{"person.csv"}) {
+		t.Errorf("Refresh(): expected added files ([person.csv]) do not match actual added files (%v)", summary.Added)
+	}
+
+	if !reflect.DeepEqual(summary.Changed, []string{"location.csv"}) {
+		t.Errorf("Refresh(): expected changed files ([location.csv]) do not match actual changed files (%v)", summary.Changed)
+	}
+
+	if !reflect.DeepEqual(summary.Removed, []string{"provider.csv"}) {
+		t.Errorf("Refresh(): expected removed files ([provider.csv]) do not match actual removed files (%v)", summary.Removed)
+	}
+
+	if !reflect.DeepEqual(summary.Unchanged, []string{"care_site.csv"}) {
+		t.Errorf("Refresh(): expected unchanged files ([care_site.csv]) do not match actual unchanged files (%v)", summary.Unchanged)
+	}
+
+	if len(d.RecordMaps) != 3 {
+		t.Fatalf("Refresh(): expected number of RecordMaps (3) does not match actual length (%d)", len(d.RecordMaps))
+	}
+
+	if d.RecordMaps[2]["table"] != "person" || d.RecordMaps[2]["etl"] != "https://persistentcodestorage.com/ETLScript3.sql" {
+		t.Errorf("Refresh(): new record not filled from existing records: %v", d.RecordMaps[2])
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after Refresh(): %s", err)
+	}
+
+}
+
+func TestRefreshSizeChanged(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		dir     string
+		past    time.Time
+		summary *datadirectory.RefreshSummary
+		err     error
+	)
+
+	dir = copyTestData(t)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.WriteMetadataToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace a file, keeping a modification time older than the metadata
+	// file, as a copy preserving times would.
+	past = time.Now().Add(-time.Hour)
+
+	if err = os.WriteFile(filepath.Join(dir, "location.csv"), []byte("location_id\n\"1\"\n\"2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Chtimes(filepath.Join(dir, "location.csv"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	d, _ = datadirectory.New(&datadirectory.Config{DataDirPath: dir})
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if summary, err = d.Refresh(); err != nil {
+		t.Fatalf("Refresh(): error in basic function: %s", err)
+	}
+
+	if !reflect.DeepEqual(summary.Changed, []string{"location.csv"}) {
+		t.Errorf("Refresh(): expected changed files ([location.csv]) do not match actual changed files (%v)", summary.Changed)
+	}
+}
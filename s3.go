@@ -0,0 +1,399 @@
+package datadirectory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Payload hash of a request without a body, and of a streamed body that is
+// not signed.
+const (
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Store is an ObjectStore for a bucket in an S3 compatible object storage
+// service, such as AWS S3 or MinIO. Requests use path style addressing,
+// Endpoint/Bucket/key, and are signed with AWS Signature Version 4 unless
+// AccessKey is empty. Region defaults to "us-east-1" and Client to
+// http.DefaultClient.
+type S3Store struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// s3ListResult is the response body of a ListObjectsV2 request.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// s3Error is the response body of a failed request.
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// List returns the objects whose keys begin with prefix, following
+// continuation tokens until the listing is complete.
+func (s *S3Store) List(prefix string) ([]*ObjectInfo, error) {
+
+	var (
+		objects []*ObjectInfo
+		token   string
+	)
+
+	objects = make([]*ObjectInfo, 0)
+
+	for {
+
+		var (
+			query  url.Values
+			resp   *http.Response
+			result s3ListResult
+			err    error
+		)
+
+		query = url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+		}
+
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		if resp, err = s.do(http.MethodGet, "", query, nil, nil, 0); err != nil {
+			return nil, err
+		}
+
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("s3 list '%s': %s", prefix, err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, &ObjectInfo{
+				Key:     content.Key,
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+// Stat returns the object with the passed key, including its sha256 checksum
+// if it was uploaded with one.
+func (s *S3Store) Stat(key string) (*ObjectInfo, error) {
+
+	var (
+		header http.Header
+		resp   *http.Response
+		info   *ObjectInfo
+		err    error
+	)
+
+	header = http.Header{
+		"X-Amz-Checksum-Mode": {"ENABLED"},
+	}
+
+	if resp, err = s.do(http.MethodHead, key, nil, header, nil, 0); err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+
+	info = &ObjectInfo{
+		Key:  key,
+		Size: resp.ContentLength,
+	}
+
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+
+	// Composite checksums are of the parts, not the whole object.
+	if resp.Header.Get("X-Amz-Checksum-Type") != "COMPOSITE" {
+		info.ChecksumSHA256 = resp.Header.Get("X-Amz-Checksum-Sha256")
+	}
+
+	return info, nil
+}
+
+// Get returns the contents of the object with the passed key.
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	if resp, err = s.do(http.MethodGet, key, nil, nil, nil, 0); err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Put uploads size bytes read from r as the object with the passed key, in a
+// single request.
+func (s *S3Store) Put(key string, r io.Reader, size int64) error {
+
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	if resp, err = s.do(http.MethodPut, key, nil, nil, r, size); err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// do sends a request for the passed object key, or for the bucket if key is
+// empty, and returns the response if its status is successful. A 404 status
+// is returned as an error wrapping fs.ErrNotExist.
+func (s *S3Store) do(method string, key string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+
+	var (
+		client  *http.Client
+		reqPath = "/" + s.Bucket
+		req     *http.Request
+		resp    *http.Response
+		s3Err   s3Error
+		err     error
+	)
+
+	if client = s.Client; client == nil {
+		client = http.DefaultClient
+	}
+
+	if key != "" {
+		reqPath += "/" + key
+	}
+
+	if req, err = http.NewRequest(method, strings.TrimSuffix(s.Endpoint, "/")+s3EscapePath(reqPath), body); err != nil {
+		return nil, err
+	}
+
+	req.URL.RawQuery = s3EscapeQuery(query)
+
+	for name, vals := range header {
+		req.Header[name] = vals
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	s.sign(req, time.Now().UTC())
+
+	if resp, err = client.Do(req); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	}
+
+	if xml.NewDecoder(resp.Body).Decode(&s3Err) != nil || s3Err.Code == "" {
+		s3Err.Code = resp.Status
+	}
+
+	return nil, fmt.Errorf("s3 %s '%s': %s %s", strings.ToLower(method), key, s3Err.Code, s3Err.Message)
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+func (s *S3Store) sign(req *http.Request, now time.Time) {
+
+	var (
+		region        = s.Region
+		payload       = s3EmptyPayload
+		amzDate       = now.Format("20060102T150405Z")
+		date          = now.Format("20060102")
+		names         []string
+		canonHeaders  strings.Builder
+		canonRequest  string
+		scope         string
+		stringToSign  string
+		key           []byte
+		signature     string
+		signedHeaders string
+	)
+
+	if req.Body != nil {
+		payload = s3UnsignedPayload
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if s.AccessKey == "" {
+		return
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// Sign the host and all x-amz-* headers.
+	names = []string{"host"}
+
+	for name := range req.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		val := req.Host
+
+		if name != "host" {
+			val = strings.Join(req.Header.Values(name), ",")
+		}
+
+		canonHeaders.WriteString(name + ":" + strings.TrimSpace(val) + "\n")
+	}
+
+	signedHeaders = strings.Join(names, ";")
+
+	canonRequest = strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope = date + "/" + region + "/s3/aws4_request"
+
+	stringToSign = strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		s3Hash([]byte(canonRequest)),
+	}, "\n")
+
+	key = []byte("AWS4" + s.SecretKey)
+
+	for _, val := range []string{date, region, "s3", "aws4_request"} {
+		key = s3HMAC(key, val)
+	}
+
+	signature = hex.EncodeToString(s3HMAC(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3Hash returns the hex encoded sha256 checksum of data.
+func s3Hash(data []byte) string {
+
+	var sum = sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// s3HMAC returns the HMAC-SHA256 of data using key.
+func s3HMAC(key []byte, data string) []byte {
+
+	var mac = hmac.New(sha256.New, key)
+
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes a request path as required for signing, leaving "/"
+// separators as they are.
+func s3EscapePath(p string) string {
+
+	var segments = strings.Split(p, "/")
+
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// s3EscapeQuery encodes query parameters in the sorted, escaped form
+// required for signing.
+func s3EscapeQuery(query url.Values) string {
+
+	var (
+		names []string
+		pairs []string
+	)
+
+	for name := range query {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, val := range query[name] {
+			pairs = append(pairs, s3Escape(name)+"="+s3Escape(val))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes every byte of s except unreserved characters.
+func s3Escape(s string) string {
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
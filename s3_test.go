@@ -0,0 +1,179 @@
+package datadirectory_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infomodels/datadirectory"
+)
+
+// fakeS3 is an in-process S3 server for a single bucket, supporting the
+// requests made by S3Store. Listings are paged two keys at a time.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	var key string
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, "/"+f.bucket) {
+		http.Error(w, "<Error><Code>NoSuchBucket</Code></Error>", http.StatusNotFound)
+		return
+	}
+
+	key = strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+f.bucket), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+		w.Write(data)
+	default:
+		http.Error(w, "<Error><Code>NotImplemented</Code></Error>", http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+
+	type content struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+
+	var (
+		keys   []string
+		start  int
+		result struct {
+			XMLName               xml.Name `xml:"ListBucketResult"`
+			Contents              []content
+			IsTruncated           bool
+			NextContinuationToken string `xml:",omitempty"`
+		}
+	)
+
+	for key := range f.objects {
+		if strings.HasPrefix(key, r.URL.Query().Get("prefix")) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	start, _ = strconv.Atoi(r.URL.Query().Get("continuation-token"))
+
+	for i := start; i < len(keys) && i < start+2; i++ {
+		result.Contents = append(result.Contents, content{keys[i], time.Unix(0, 0).UTC(), int64(len(f.objects[keys[i]]))})
+	}
+
+	if start+2 < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = strconv.Itoa(start + 2)
+	}
+
+	xml.NewEncoder(w).Encode(&result)
+}
+
+func TestS3Store(t *testing.T) {
+
+	var (
+		server  *httptest.Server
+		store   *datadirectory.S3Store
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		objects []*datadirectory.ObjectInfo
+		err     error
+	)
+
+	server = httptest.NewServer(&fakeS3{bucket: "extracts", objects: make(map[string][]byte)})
+	defer server.Close()
+
+	store = &datadirectory.S3Store{
+		Endpoint:  server.URL,
+		Bucket:    "extracts",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}
+
+	putTestData(t, store, "site a/2024/")
+
+	if objects, err = store.List("site a/"); err != nil {
+		t.Fatalf("List(): error in basic function: %s", err)
+	}
+
+	if len(objects) != 3 {
+		t.Errorf("List(): expected 3 objects across pages, got %d", len(objects))
+	}
+
+	if _, err = store.Stat("site a/2024/person.csv"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat(): expected fs.ErrNotExist for missing key, got %v", err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+		FS:           datadirectory.NewObjectFS(store, "site a/2024"),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatalf("PopulateMetadataFromData(): error listing bucket: %s", err)
+	}
+
+	if err = d.WriteMetadataToFile(); err != nil {
+		t.Fatalf("WriteMetadataToFile(): error writing to bucket: %s", err)
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatalf("ReadMetadataFromFile(): error reading from bucket: %s", err)
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error validating bucket: %s", err)
+	}
+
+	store.SecretKey = ""
+	store.AccessKey = ""
+
+	if _, err = store.List(""); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("List(): expected AccessDenied error for anonymous request, got %v", err)
+	}
+}
@@ -0,0 +1,492 @@
+package datadirectory
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// sampler holds the state of a Sample: the selected persons, the tables
+// scoped to persons, the tables they reference, directly or not, and the key
+// values of the rows kept so far.
+type sampler struct {
+	d       *DataDirectory
+	keys    []*ForeignKey
+	scoped  map[string]bool
+	parents map[string]bool
+	persons map[string]bool
+	// kept holds the values of the key fields of the kept rows of scoped
+	// tables referenced by other scoped tables, and referenced holds the
+	// values referenced by kept rows in tables that are not scoped, both
+	// keyed by keySetName.
+	kept       map[string]map[string]bool
+	referenced map[string]map[string]bool
+}
+
+// SampleOptions configures a Sample. Exactly one of Persons, the number of
+// persons to sample, and Fraction, the fraction of them from above 0 to 1,
+// must be set. Seed picks the persons.
+type SampleOptions struct {
+	Persons  int
+	Fraction float64
+	Seed     int64
+}
+
+// Sample writes a sample of the data files listed in the metadata to a new
+// data directory at dirPath, which must not exist yet. Persons are picked by
+// a hash of their person_id and the seed, so the same seed picks the same
+// persons. Foreign keys, from the data models service
+// and the DataDirectory ForeignKeys, are followed from the person table to
+// keep only the rows of the sampled persons in tables that reference it,
+// directly or through other tables, and only the rows referenced by kept
+// rows in the tables they reference, such as provider and care_site.
+// Tables not linked to the person table are copied whole. The new directory
+// gets its own metadata.csv and a transforms.json file recording the sample.
+func (d *DataDirectory) Sample(dirPath string, opts SampleOptions) (*DataDirectory, error) {
+
+	var (
+		s      *sampler
+		order  []string
+		record *TransformRecord
+		err    error
+	)
+
+	if (opts.Persons == 0) == (opts.Fraction == 0) {
+		return nil, errors.New("sampling requires either a number of persons or a fraction")
+	}
+
+	if opts.Persons < 0 {
+		return nil, errors.New("the number of persons to sample must be positive")
+	}
+
+	if opts.Fraction < 0 || opts.Fraction > 1 {
+		return nil, errors.New("the fraction of persons to sample must be above 0 and at most 1")
+	}
+
+	if len(d.RecordsByTable("person")) == 0 {
+		return nil, errors.New("sampling requires a person table")
+	}
+
+	s = &sampler{
+		d:          d,
+		keys:       d.foreignKeys(),
+		kept:       make(map[string]map[string]bool),
+		referenced: make(map[string]map[string]bool),
+	}
+
+	if s.persons, err = s.selectPersons(opts); err != nil {
+		return nil, err
+	}
+
+	s.scopeTables()
+
+	// Read the scoped tables, then the tables they reference, collecting
+	// the key values of the kept rows.
+	if order, err = s.tableOrder(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range order {
+		if err = s.collect(table); err != nil {
+			return nil, err
+		}
+	}
+
+	record = &TransformRecord{
+		Transform: "sample",
+		Parameters: map[string]string{
+			"seed":    strconv.FormatInt(opts.Seed, 10),
+			"persons": strconv.Itoa(len(s.persons)),
+		},
+	}
+
+	if opts.Fraction > 0 {
+		record.Parameters["fraction"] = strconv.FormatFloat(opts.Fraction, 'f', -1, 64)
+	} else {
+		record.Parameters["count"] = strconv.Itoa(opts.Persons)
+	}
+
+	return d.transform(dirPath, func(table string, header []string) (func(row []string) (bool, error), error) {
+
+		keep, err := s.keep(table, header)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(row []string) (bool, error) {
+			return keep(row), nil
+		}, nil
+	}, record)
+}
+
+// selectPersons returns the person_id values of the sampled persons: those
+// with the lowest hashes, or with hashes below the fraction of the range.
+func (s *sampler) selectPersons(opts SampleOptions) (map[string]bool, error) {
+
+	type hashedPerson struct {
+		hash uint64
+		id   string
+	}
+
+	var (
+		reader  *TableReader
+		columns []int
+		hashed  []hashedPerson
+		persons = make(map[string]bool)
+		err     error
+	)
+
+	if reader, err = s.d.OpenTable("person"); err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	for reader.Next() {
+
+		if reader.FileChanged() {
+			if columns, err = reader.Columns([]string{"person_id"}); err != nil {
+				return nil, err
+			}
+		}
+
+		id, ok := keyValue(reader.Values(), columns)
+
+		if !ok {
+			continue
+		}
+
+		hash := sampleHash(opts.Seed, id)
+
+		if opts.Fraction > 0 {
+			if opts.Fraction == 1 || float64(hash) < opts.Fraction*math.MaxUint64 {
+				persons[id] = true
+			}
+			continue
+		}
+
+		hashed = append(hashed, hashedPerson{hash, id})
+	}
+
+	if err = reader.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hashed, func(i, j int) bool {
+		return hashed[i].hash < hashed[j].hash
+	})
+
+	for i := 0; i < len(hashed) && i < opts.Persons; i++ {
+		persons[hashed[i].id] = true
+	}
+
+	return persons, nil
+}
+
+// sampleHash returns the hash of a person_id for the passed seed.
+func sampleHash(seed int64, id string) uint64 {
+
+	var (
+		buf [8]byte
+		sum [sha256.Size]byte
+	)
+
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	sum = sha256.Sum256(append(buf[:], id...))
+
+	return binary.BigEndian.Uint64(sum[:])
+}
+
+// scopeTables finds the tables scoped to persons, the person table and the
+// tables with a foreign key to a scoped table, and their parents, the tables
+// referenced by scoped tables or by other parents that are not scoped.
+func (s *sampler) scopeTables() {
+
+	var changed = true
+
+	s.scoped = map[string]bool{"person": true}
+	s.parents = make(map[string]bool)
+
+	for changed {
+
+		changed = false
+
+		for _, key := range s.keys {
+			if !s.scoped[key.Table] && s.scoped[key.ReferencesTable] {
+				s.scoped[key.Table] = true
+				changed = true
+			}
+		}
+	}
+
+	for changed = true; changed; {
+
+		changed = false
+
+		for _, key := range s.keys {
+			if (s.scoped[key.Table] || s.parents[key.Table]) && !s.scoped[key.ReferencesTable] && !s.parents[key.ReferencesTable] {
+				s.parents[key.ReferencesTable] = true
+				changed = true
+			}
+		}
+	}
+}
+
+// tableOrder returns the scoped tables, each after the scoped tables it
+// references, followed by their parents, each after the tables that
+// reference it. Self references are ignored.
+func (s *sampler) tableOrder() ([]string, error) {
+
+	var (
+		order   []string
+		done    = make(map[string]bool)
+		changed bool
+	)
+
+	// ready reports whether every table a table depends on is done: the
+	// scoped tables it references, if it is scoped, or the tables that
+	// reference it otherwise.
+	ready := func(table string) bool {
+
+		for _, key := range s.keys {
+
+			if key.Table == key.ReferencesTable {
+				continue
+			}
+
+			if s.scoped[table] && key.Table == table && s.scoped[key.ReferencesTable] && !done[key.ReferencesTable] {
+				return false
+			}
+
+			if s.parents[table] && key.ReferencesTable == table && (s.scoped[key.Table] || s.parents[key.Table]) && !done[key.Table] {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, group := range []map[string]bool{s.scoped, s.parents} {
+
+		for changed = true; changed; {
+
+			changed = false
+
+			for _, table := range s.d.Tables() {
+				if group[table] && !done[table] && ready(table) {
+					order = append(order, table)
+					done[table] = true
+					changed = true
+				}
+			}
+		}
+
+		for _, table := range s.d.Tables() {
+			if group[table] && !done[table] {
+				return nil, fmt.Errorf("table '%s' foreign keys form a cycle", table)
+			}
+		}
+	}
+
+	return order, nil
+}
+
+// collect reads a table, adding the key values of its kept rows to the key
+// sets of the sampler.
+func (s *sampler) collect(table string) error {
+
+	var (
+		reader *TableReader
+		keep   func(row []string) bool
+		add    func(row []string)
+		err    error
+	)
+
+	if reader, err = s.d.OpenTable(table); err != nil {
+		return err
+	}
+
+	defer reader.Close()
+
+	for reader.Next() {
+
+		if reader.FileChanged() {
+
+			if keep, err = s.keep(table, reader.Header()); err != nil {
+				return fmt.Errorf("file '%s' %s", reader.Filename(), err)
+			}
+
+			if add, err = s.adder(table, reader.Header()); err != nil {
+				return fmt.Errorf("file '%s' %s", reader.Filename(), err)
+			}
+		}
+
+		if keep(reader.Values()) {
+			add(reader.Values())
+		}
+	}
+
+	return reader.Err()
+}
+
+// keep returns the function reporting whether a row of the passed table and
+// header is kept. Rows of the person table are kept for sampled persons.
+// Rows of tables referenced by scoped tables are kept if referenced by a
+// kept row. Rows of other tables are kept if each of their references to a
+// scoped table, if they are scoped, or to a table referenced by scoped
+// tables, otherwise, is to a kept row; empty references are not checked.
+func (s *sampler) keep(table string, header []string) (func(row []string) bool, error) {
+
+	var (
+		columns [][]int
+		sets    []map[string]bool
+		err     error
+	)
+
+	switch {
+	case table == "person":
+
+		var cols []int
+
+		if cols, err = fieldColumns(header, []string{"person_id"}); err != nil {
+			return nil, err
+		}
+
+		return func(row []string) bool {
+			value, ok := keyValue(row, cols)
+			return ok && s.persons[value]
+		}, nil
+
+	case s.parents[table]:
+
+		for _, key := range s.keys {
+
+			var cols []int
+
+			if key.ReferencesTable != table || key.Table == table || !s.scoped[key.Table] && !s.parents[key.Table] {
+				continue
+			}
+
+			if cols, err = fieldColumns(header, key.ReferencesFields); err != nil {
+				return nil, err
+			}
+
+			columns = append(columns, cols)
+			sets = append(sets, s.keySet(s.referenced, table, key.ReferencesFields))
+		}
+
+		return func(row []string) bool {
+
+			for i, cols := range columns {
+				if value, ok := keyValue(row, cols); ok && sets[i][value] {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	}
+
+	for _, key := range s.keys {
+
+		var cols []int
+
+		if key.Table != table || key.ReferencesTable == table {
+			continue
+		}
+
+		if s.scoped[table] && s.scoped[key.ReferencesTable] {
+			sets = append(sets, s.keySet(s.kept, key.ReferencesTable, key.ReferencesFields))
+		} else if !s.scoped[table] && s.parents[key.ReferencesTable] {
+			sets = append(sets, s.keySet(s.referenced, key.ReferencesTable, key.ReferencesFields))
+		} else {
+			continue
+		}
+
+		// Foreign key fields missing from a file have no values to check.
+		if cols, err = fieldColumns(header, key.Fields); err != nil {
+			sets = sets[:len(sets)-1]
+			continue
+		}
+
+		columns = append(columns, cols)
+	}
+
+	return func(row []string) bool {
+
+		for i, cols := range columns {
+			if value, ok := keyValue(row, cols); ok && !sets[i][value] {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// adder returns the function adding the key values of a kept row of the
+// passed table and header to the key sets: the values of its fields
+// referenced by scoped tables, if it is scoped, and the values of its
+// references to tables that are not scoped.
+func (s *sampler) adder(table string, header []string) (func(row []string), error) {
+
+	var (
+		columns [][]int
+		sets    []map[string]bool
+		err     error
+	)
+
+	for _, key := range s.keys {
+
+		var cols []int
+
+		switch {
+		case key.ReferencesTable == table && s.scoped[table] && s.scoped[key.Table]:
+
+			if cols, err = fieldColumns(header, key.ReferencesFields); err != nil {
+				return nil, err
+			}
+
+			sets = append(sets, s.keySet(s.kept, table, key.ReferencesFields))
+
+		case key.Table == table && !s.scoped[key.ReferencesTable]:
+
+			if cols, err = fieldColumns(header, key.Fields); err != nil {
+				continue
+			}
+
+			sets = append(sets, s.keySet(s.referenced, key.ReferencesTable, key.ReferencesFields))
+
+		default:
+			continue
+		}
+
+		columns = append(columns, cols)
+	}
+
+	return func(row []string) {
+		for i, cols := range columns {
+			if value, ok := keyValue(row, cols); ok {
+				sets[i][value] = true
+			}
+		}
+	}, nil
+}
+
+// keySet returns the key set for the passed fields of a table, creating it
+// if needed.
+func (s *sampler) keySet(sets map[string]map[string]bool, table string, fields []string) map[string]bool {
+
+	var name = keySetName(table, fields)
+
+	if sets[name] == nil {
+		sets[name] = make(map[string]bool)
+	}
+
+	return sets[name]
+}
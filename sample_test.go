@@ -0,0 +1,88 @@
+package datadirectory_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestSample(t *testing.T) {
+
+	var (
+		d          *datadirectory.DataDirectory
+		dst        *datadirectory.DataDirectory
+		violations []*datadirectory.ReferenceViolation
+		err        error
+	)
+
+	d = personTestData(t)
+
+	if dst, err = d.Sample(filepath.Join(t.TempDir(), "sample"), datadirectory.SampleOptions{Persons: 1, Seed: 7}); err != nil {
+		t.Fatalf("Sample(): error in basic function: %s", err)
+	}
+
+	if err = dst.Validate(); err != nil {
+		t.Errorf("Sample(): sampled data directory does not validate: %s", err)
+	}
+
+	if violations, err = dst.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, violation := range violations {
+		t.Errorf("Sample(): orphan references from '%s' to '%s'", violation.ForeignKey.Table, violation.ForeignKey.ReferencesTable)
+	}
+
+	people := tableRows(t, dst, "person")
+
+	if len(people) != 1 {
+		t.Fatalf("Sample(): expected 1 person, got %d", len(people))
+	}
+
+	visits := tableRows(t, dst, "visit_occurrence")
+
+	if len(visits) != 1 || visits[0]["person_id"] != people[0]["person_id"] {
+		t.Errorf("Sample(): expected only the visit of person %s, got %v", people[0]["person_id"], visits)
+	}
+
+	// Only the care sites referenced by the sampled rows are kept, along
+	// with the location they reference in turn.
+	sites := tableRows(t, dst, "care_site")
+
+	if len(sites) != 1 || sites[0]["care_site_id"] != people[0]["care_site_id"] {
+		t.Errorf("Sample(): expected only care site %s, got %v", people[0]["care_site_id"], sites)
+	}
+
+	if rows := tableRows(t, dst, "provider"); len(rows) >= 3 {
+		t.Errorf("Sample(): expected unreferenced providers to be dropped, got %d", len(rows))
+	}
+
+	if rows := tableRows(t, dst, "location"); len(rows) != 1 {
+		t.Errorf("Sample(): expected 1 location, got %d", len(rows))
+	}
+
+	// The same seed samples the same persons, and a fraction of 1 all of
+	// them.
+	if dst, err = d.Sample(filepath.Join(t.TempDir(), "again"), datadirectory.SampleOptions{Persons: 1, Seed: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	if tableRows(t, dst, "person")[0]["person_id"] != people[0]["person_id"] {
+		t.Errorf("Sample(): same seed sampled a different person")
+	}
+
+	if dst, err = d.Sample(filepath.Join(t.TempDir(), "all"), datadirectory.SampleOptions{Fraction: 1, Seed: 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rows := tableRows(t, dst, "person"); len(rows) != 2 {
+		t.Errorf("Sample(): expected both persons with a fraction of 1, got %d", len(rows))
+	}
+
+	for _, opts := range []datadirectory.SampleOptions{{}, {Persons: 1, Fraction: 0.5}, {Persons: -1}, {Fraction: 1.5}} {
+		if _, err = d.Sample(filepath.Join(t.TempDir(), "bad"), opts); err == nil {
+			t.Errorf("Sample(): no error thrown for options %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package datadirectory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Maximum number of example lines kept for each scan finding, and the
+// maximum length of a redacted sample.
+const (
+	maxScanExamples = 5
+	maxSampleLength = 80
+)
+
+// defaultScanColumns are the columns scanned when the ScanOptions Columns
+// are not set: the free-text source values.
+var defaultScanColumns = []string{"*_source_value"}
+
+// ScanOptions configures a Scan. Columns are path.Match patterns of the
+// columns to scan, defaulting to "*_source_value", and Detectors default to
+// DefaultDetectors. Matches in the Allowlist, or in values that are, are
+// ignored.
+type ScanOptions struct {
+	Columns   []string
+	Detectors []*Detector
+	Allowlist []string
+}
+
+// Detector flags values containing a match of its regular expression
+// Pattern, such as a social security number.
+type Detector struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// ScanFinding reports the lines of a table column with values flagged by a
+// detector. Count is the number of such lines, of which at most a few are
+// kept in Examples.
+type ScanFinding struct {
+	Table    string       `json:"table"`
+	Column   string       `json:"column"`
+	Detector string       `json:"detector"`
+	Count    int64        `json:"count"`
+	Examples []*ScanMatch `json:"examples"`
+}
+
+// ScanMatch is a line of a data file with a flagged value. Sample is the
+// value with every match redacted.
+type ScanMatch struct {
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Sample   string `json:"sample"`
+}
+
+// DefaultDetectors returns detectors for social security numbers, phone
+// numbers, email addresses, medical record numbers labeled as such and full
+// dates.
+func DefaultDetectors() []*Detector {
+	return []*Detector{
+		{Name: "ssn", Pattern: `\b\d{3}[- ]\d{2}[- ]\d{4}\b`},
+		{Name: "phone", Pattern: `(?:\(\d{3}\)\s*|\b\d{3}[-. ])\d{3}[-. ]\d{4}\b`},
+		{Name: "email", Pattern: `(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`},
+		{Name: "mrn", Pattern: `(?i)\bmrn\s*[:#]?\s*\d{4,}\b`},
+		{Name: "date", Pattern: `\b(?:\d{4}-\d{1,2}-\d{1,2}|\d{1,2}/\d{1,2}/\d{2,4})\b`},
+	}
+}
+
+// ReadDetectors reads detectors from a JSON array of objects with "name" and
+// "pattern" properties, for use as the ScanOptions Detectors.
+func ReadDetectors(r io.Reader) ([]*Detector, error) {
+
+	var (
+		detectors []*Detector
+		err       error
+	)
+
+	if err = json.NewDecoder(r).Decode(&detectors); err != nil {
+		return nil, err
+	}
+
+	for i, detector := range detectors {
+
+		if detector.Name == "" || detector.Pattern == "" {
+			return nil, fmt.Errorf("detector %d must have a name and a pattern", i+1)
+		}
+
+		if _, err = regexp.Compile(detector.Pattern); err != nil {
+			return nil, fmt.Errorf("detector '%s' pattern is not valid: %s", detector.Name, err)
+		}
+	}
+
+	return detectors, nil
+}
+
+// ReadAllowlist reads an allowlist with one value per line, for use as the
+// ScanOptions Allowlist. Surrounding whitespace is ignored, as are empty
+// lines and lines starting with '#'.
+func ReadAllowlist(r io.Reader) ([]string, error) {
+
+	var (
+		scanner   *bufio.Scanner
+		allowlist []string
+	)
+
+	scanner = bufio.NewScanner(r)
+
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		allowlist = append(allowlist, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return allowlist, nil
+}
+
+// Scan reads the data files listed in the metadata and returns a finding for
+// each table column and detector with flagged values, in table, column and
+// detector order.
+func (d *DataDirectory) Scan(opts ScanOptions) ([]*ScanFinding, error) {
+
+	var (
+		detectors []*Detector
+		patterns  []*regexp.Regexp
+		columns   []string
+		allowed   map[string]bool
+		findings  []*ScanFinding
+		err       error
+	)
+
+	if detectors = opts.Detectors; len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+
+	for _, detector := range detectors {
+
+		var pattern *regexp.Regexp
+
+		if pattern, err = regexp.Compile(detector.Pattern); err != nil {
+			return nil, fmt.Errorf("detector '%s' pattern is not valid: %s", detector.Name, err)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	if columns = opts.Columns; len(columns) == 0 {
+		columns = defaultScanColumns
+	}
+
+	allowed = make(map[string]bool)
+
+	for _, value := range opts.Allowlist {
+		allowed[value] = true
+	}
+
+	for _, table := range d.Tables() {
+
+		var tableFindings []*ScanFinding
+
+		if tableFindings, err = d.scanTable(table, columns, detectors, patterns, allowed); err != nil {
+			return nil, err
+		}
+
+		findings = append(findings, tableFindings...)
+	}
+
+	return findings, nil
+}
+
+// scanTable scans the data files of a table.
+func (d *DataDirectory) scanTable(table string, columns []string, detectors []*Detector, patterns []*regexp.Regexp, allowed map[string]bool) ([]*ScanFinding, error) {
+
+	var (
+		reader   *TableReader
+		found    map[string][]*ScanFinding
+		order    []string
+		scanned  []int
+		findings []*ScanFinding
+		err      error
+	)
+
+	if reader, err = d.OpenTable(table); err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	found = make(map[string][]*ScanFinding)
+
+	for reader.Next() {
+
+		if reader.FileChanged() {
+
+			scanned = scanned[:0]
+
+			for i, column := range reader.Header() {
+				if scanColumn(strings.ToLower(column), columns) {
+					scanned = append(scanned, i)
+				}
+			}
+		}
+
+		for _, i := range scanned {
+
+			var (
+				column = strings.ToLower(reader.Header()[i])
+				value  string
+			)
+
+			if i >= len(reader.Values()) {
+				continue
+			}
+
+			if value = reader.Values()[i]; value == "" || allowed[value] {
+				continue
+			}
+
+			for j, pattern := range patterns {
+
+				if !scanMatches(pattern, value, allowed) {
+					continue
+				}
+
+				if found[column] == nil {
+					found[column] = make([]*ScanFinding, len(detectors))
+					order = append(order, column)
+				}
+
+				if found[column][j] == nil {
+					found[column][j] = &ScanFinding{
+						Table:    table,
+						Column:   column,
+						Detector: detectors[j].Name,
+					}
+				}
+
+				found[column][j].Count++
+
+				if len(found[column][j].Examples) < maxScanExamples {
+					found[column][j].Examples = append(found[column][j].Examples, &ScanMatch{
+						Filename: reader.Filename(),
+						Line:     reader.Line(),
+						Sample:   redact(value, patterns),
+					})
+				}
+			}
+		}
+	}
+
+	if err = reader.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, column := range order {
+		for _, finding := range found[column] {
+			if finding != nil {
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// scanColumn reports whether the column matches any of the patterns.
+func scanColumn(column string, patterns []string) bool {
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), column); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanMatches reports whether the value has a match of the pattern that is
+// not allowed.
+func scanMatches(pattern *regexp.Regexp, value string, allowed map[string]bool) bool {
+
+	for _, match := range pattern.FindAllString(value, -1) {
+		if !allowed[match] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redact returns the value with the letters and digits of every match of the
+// patterns replaced by 'X', except for the last two of each match, shortened
+// to maxSampleLength characters.
+func redact(value string, patterns []*regexp.Regexp) string {
+
+	var masked = []byte(value)
+
+	// Only ASCII bytes are replaced, so the value remains valid UTF-8.
+	for _, pattern := range patterns {
+		for _, loc := range pattern.FindAllStringIndex(value, -1) {
+
+			keep := 2
+
+			for i := loc[1] - 1; i >= loc[0]; i-- {
+
+				if !isAlphanumeric(value[i]) {
+					continue
+				}
+
+				if keep > 0 {
+					keep--
+					continue
+				}
+
+				masked[i] = 'X'
+			}
+		}
+	}
+
+	if runes := []rune(string(masked)); len(runes) > maxSampleLength {
+		return string(runes[:maxSampleLength]) + "..."
+	}
+
+	return string(masked)
+}
+
+// isAlphanumeric reports whether b is an ASCII letter or digit.
+func isAlphanumeric(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,119 @@
+package datadirectory_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestScan(t *testing.T) {
+
+	var (
+		cfg      *datadirectory.Config
+		d        *datadirectory.DataDirectory
+		findings []*datadirectory.ScanFinding
+		err      error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: copyTestData(t),
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if findings, err = d.Scan(datadirectory.ScanOptions{}); err != nil {
+		t.Fatalf("Scan(): error in basic function: %s", err)
+	}
+
+	if len(findings) != 0 {
+		t.Errorf("Scan(): expected no findings in test data, got %d", len(findings))
+	}
+}
+
+func TestScanFindings(t *testing.T) {
+
+	var (
+		cfg       *datadirectory.Config
+		d         *datadirectory.DataDirectory
+		dir       string
+		allowlist []string
+		findings  []*datadirectory.ScanFinding
+		err       error
+	)
+
+	dir = copyTestData(t)
+
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), []byte("provider_id,provider_source_value,specialty_source_value\n\"1\",\"Dr A 123-45-6789\",\"555-123-4567\"\n\"2\",\"jane.doe@example.org\",\"call (215) 555-0100\"\n\"3\",\"seen 2014-03-07\",\n\"4\",\"MRN: 00123456\",\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if allowlist, err = datadirectory.ReadAllowlist(strings.NewReader("# Main switchboard.\n555-123-4567\n\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("provider.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if findings, err = d.Scan(datadirectory.ScanOptions{Allowlist: allowlist}); err != nil {
+		t.Fatalf("Scan(): error in basic function: %s", err)
+	}
+
+	var got []string
+
+	for _, finding := range findings {
+		for _, match := range finding.Examples {
+			got = append(got, fmt.Sprintf("%s|%s|%s|%s|%d|%s", finding.Table, finding.Column, finding.Detector, match.Filename, match.Line, match.Sample))
+		}
+	}
+
+	expected := []string{
+		"provider|provider_source_value|ssn|provider.csv|2|Dr A XXX-XX-XX89",
+		"provider|provider_source_value|email|provider.csv|3|XXXX.XXX@XXXXXXX.Xrg",
+		"provider|provider_source_value|mrn|provider.csv|5|XXX: XXXXXX56",
+		"provider|provider_source_value|date|provider.csv|4|seen XXXX-XX-07",
+		"provider|specialty_source_value|phone|provider.csv|3|call (XXX) XXX-XX00",
+	}
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Scan(): unexpected findings:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestReadDetectors(t *testing.T) {
+
+	var (
+		detectors []*datadirectory.Detector
+		err       error
+	)
+
+	if detectors, err = datadirectory.ReadDetectors(strings.NewReader(`[{"name": "study-id", "pattern": "\\bSTUDY-\\d+\\b"}]`)); err != nil {
+		t.Fatalf("ReadDetectors(): error in basic function: %s", err)
+	}
+
+	if len(detectors) != 1 || detectors[0].Name != "study-id" {
+		t.Errorf("ReadDetectors(): unexpected detectors %v", detectors)
+	}
+
+	if _, err = datadirectory.ReadDetectors(strings.NewReader(`[{"name": "bad", "pattern": "("}]`)); err == nil {
+		t.Errorf("ReadDetectors(): no error thrown for invalid pattern")
+	}
+}
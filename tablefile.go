@@ -0,0 +1,310 @@
+package datadirectory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of bytes kept to check the header of a table file.
+const maxHeaderBytes = 64 * 1024
+
+// TableFileWriter writes a data file for a table, calculating its checksum
+// and counting its rows as it is written. Closing it adds the record for the
+// file to the DataDirectory. It is created by CreateTableFile.
+type TableFileWriter struct {
+
+	// CheckHeader makes Close check the header of the file against the
+	// model definition of the table. It is off by default, since data
+	// files may have columns the model does not define.
+	CheckHeader bool
+
+	d           *DataDirectory
+	file        *os.File
+	data        io.WriteCloser
+	relPath     string
+	table       string
+	compression string
+	sum         hash.Hash
+	counter     *csvRowCounter
+	header      bytes.Buffer
+	closed      bool
+}
+
+// CreateTableFile creates a new data file for the passed table in the data
+// directory and returns a writer for it. The file is named after the table,
+// numbered if a file for the table already exists. The table must exist in
+// the DataDirectory model and version in the data models service. The record
+// for the file, with its checksum, is added to the RecordMaps on Close, so
+// the file never has to be read again.
+//
+// The writer is an io.WriteCloser. The concrete type is returned so callers
+// can also get the file name and row count, set CheckHeader and Abort a file
+// that could not be written.
+func (d *DataDirectory) CreateTableFile(table string) (*TableFileWriter, error) {
+
+	var (
+		relPath string
+		writer  *TableFileWriter
+		err     error
+	)
+
+	table = strings.ToLower(table)
+
+	// Find a free file name.
+	for n := 1; ; n++ {
+
+		relPath = table + ".csv"
+
+		if n > 1 {
+			relPath = table + "_" + strconv.Itoa(n) + ".csv"
+		}
+
+		if d.recordIndex(relPath) >= 0 {
+			continue
+		}
+
+		if writer, err = d.createTableFile(table, relPath, ""); os.IsExist(err) {
+			continue
+		}
+
+		return writer, err
+	}
+}
+
+// createTableFile creates a new data file for the passed table at relPath,
+// which must not exist, creating its directory if needed, and returns a
+// writer compressing what is written to it with the passed compression.
+func (d *DataDirectory) createTableFile(table string, relPath string, compression string) (*TableFileWriter, error) {
+
+	var (
+		path = filepath.Join(d.DirPath, relPath)
+		file *os.File
+		data io.WriteCloser
+		sum  = sha256.New()
+		err  error
+	)
+
+	if err = d.checkTable(d.Model, d.ModelVersion, table); err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644); err != nil {
+		return nil, err
+	}
+
+	// The checksum is of the stored, compressed bytes.
+	if data, err = compressWriter(io.MultiWriter(file, sum), compression); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("file '%s' %s", relPath, err)
+	}
+
+	return &TableFileWriter{
+		d:           d,
+		file:        file,
+		data:        data,
+		relPath:     relPath,
+		table:       table,
+		compression: compression,
+		sum:         sum,
+		counter:     &csvRowCounter{},
+	}, nil
+}
+
+// Write writes to the data file, compressing it if needed and updating the
+// checksum and row count.
+func (w *TableFileWriter) Write(p []byte) (int, error) {
+
+	var (
+		n   int
+		err error
+	)
+
+	if w.closed {
+		return 0, errors.New("write to closed table file")
+	}
+
+	n, err = w.data.Write(p)
+
+	w.counter.Write(p[:n])
+
+	// Keep the beginning of the file to check the header.
+	if room := maxHeaderBytes - w.header.Len(); w.CheckHeader && room > 0 {
+		if room > n {
+			room = n
+		}
+		w.header.Write(p[:room])
+	}
+
+	return n, err
+}
+
+// Filename returns the name of the data file, relative to the data
+// directory.
+func (w *TableFileWriter) Filename() string {
+	return w.relPath
+}
+
+// Rows returns the number of rows written so far, excluding the header.
+func (w *TableFileWriter) Rows() int64 {
+
+	return dataRows(w.counter)
+}
+
+// Close closes the data file and adds the record for the file to the
+// DataDirectory RecordMaps. If CheckHeader is set and the header is not valid
+// for the model definition of the table, the file is removed instead.
+func (w *TableFileWriter) Close() error {
+
+	var (
+		recordMap map[string]string
+		fi        os.FileInfo
+		err       error
+	)
+
+	if w.closed {
+		return nil
+	}
+
+	if err = w.data.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+
+	if fi, err = w.file.Stat(); err != nil {
+		w.Abort()
+		return err
+	}
+
+	w.closed = true
+
+	if err = w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return err
+	}
+
+	if w.CheckHeader {
+		if err = w.d.checkHeader(w.relPath, w.table, w.header.Bytes()); err != nil {
+			os.Remove(w.file.Name())
+			return err
+		}
+	}
+
+	if w.compression != "" {
+		w.d.addHeader("compression")
+	}
+
+	recordMap = w.d.newRecordMap(w.relPath, &fileHash{
+		checksum: hex.EncodeToString(w.sum.Sum(nil)),
+		size:     fi.Size(),
+		rows:     w.Rows(),
+	}, w.table)
+
+	if w.compression != "" {
+		recordMap["compression"] = w.compression
+	}
+
+	w.d.appendRecord(recordMap)
+
+	return nil
+}
+
+// Abort closes and removes the data file without adding a record for it,
+// for use when writing the file fails. It does nothing after Close.
+func (w *TableFileWriter) Abort() {
+
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+
+	w.data.Close()
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// checkHeader returns an error if the header row at the start of data is
+// missing or contains columns that are not fields of the table in the
+// DataDirectory model and version.
+func (d *DataDirectory) checkHeader(relPath string, table string, data []byte) error {
+
+	var (
+		header []string
+		fields map[string]bool
+		err    error
+	)
+
+	if header, err = csv.NewReader(bytes.NewReader(data)).Read(); err != nil {
+		return fmt.Errorf("file '%s' header could not be read: %s", relPath, err)
+	}
+
+	fields = make(map[string]bool)
+
+	for _, field := range d.modelTable(d.Model, d.ModelVersion, table).Fields {
+		fields[strings.ToLower(field.Name)] = true
+	}
+
+	for _, column := range header {
+		if !fields[strings.ToLower(column)] {
+			return fmt.Errorf("file '%s' column '%s' not found in data models service table '%s'", relPath, column, table)
+		}
+	}
+
+	return nil
+}
+
+// csvRowCounter is a writer that counts the csv records written to it,
+// accounting for quoted line breaks and skipping empty lines like
+// csv.Reader.
+type csvRowCounter struct {
+	records  int64
+	inQuotes bool
+	pending  bool
+}
+
+// Write counts the records ended in p.
+func (c *csvRowCounter) Write(p []byte) (int, error) {
+
+	for _, b := range p {
+		switch {
+		case b == '"':
+			c.inQuotes = !c.inQuotes
+			c.pending = true
+		case b == '\n' && !c.inQuotes:
+			if c.pending {
+				c.records++
+			}
+			c.pending = false
+		case b != '\r':
+			c.pending = true
+		}
+	}
+
+	return len(p), nil
+}
+
+// count returns the number of records, including a final record without a
+// line break.
+func (c *csvRowCounter) count() int64 {
+
+	if c.pending {
+		return c.records + 1
+	}
+
+	return c.records
+}
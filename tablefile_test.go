@@ -0,0 +1,127 @@
+package datadirectory_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestCreateTableFile(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		writer *datadirectory.TableFileWriter
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	for i := 0; i < 2; i++ {
+
+		if writer, err = d.CreateTableFile("location"); err != nil {
+			t.Fatalf("CreateTableFile(): error in basic function: %s", err)
+		}
+
+		io.WriteString(writer, "location_id,zip\n\"1\",\"19104\"\n\"2\",\"multi\nline\"\n")
+
+		if writer.Rows() != 2 {
+			t.Errorf("Rows(): expected number of rows (2) does not match actual number (%d)", writer.Rows())
+		}
+
+		if err = writer.Close(); err != nil {
+			t.Fatalf("Close(): error in basic function: %s", err)
+		}
+	}
+
+	if len(d.RecordMaps) != 2 || d.RecordMaps[1]["filename"] != "location_2.csv" {
+		t.Fatalf("CreateTableFile(): unexpected records: %v", d.RecordMaps)
+	}
+
+	if err = d.Validate(); err != nil {
+		t.Errorf("Validate(): error after CreateTableFile(): %s", err)
+	}
+
+}
+
+func TestCreateTableFileBadHeader(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		writer *datadirectory.TableFileWriter
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if _, err = d.CreateTableFile("foo"); err == nil {
+		t.Errorf("CreateTableFile(): no error thrown for unknown table")
+	}
+
+	if writer, err = d.CreateTableFile("location"); err != nil {
+		t.Fatalf("CreateTableFile(): error in basic function: %s", err)
+	}
+
+	writer.CheckHeader = true
+	io.WriteString(writer, "location_id,foo\n\"1\",\"2\"\n")
+
+	if err = writer.Close(); err == nil {
+		t.Errorf("Close(): no error thrown for unknown column")
+	}
+
+	if len(d.RecordMaps) != 0 {
+		t.Errorf("Close(): record added for file with invalid header")
+	}
+
+	if _, err = os.Stat(filepath.Join(cfg.DataDirPath, writer.Filename())); !os.IsNotExist(err) {
+		t.Errorf("Close(): file with invalid header not removed")
+	}
+
+}
+
+func TestCreateTableFileExtraColumn(t *testing.T) {
+
+	var (
+		d      *datadirectory.DataDirectory
+		writer *datadirectory.TableFileWriter
+		err    error
+	)
+
+	d, _ = datadirectory.New(&datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+	})
+
+	if writer, err = d.CreateTableFile("location"); err != nil {
+		t.Fatalf("CreateTableFile(): error in basic function: %s", err)
+	}
+
+	io.WriteString(writer, "location_id,site_note\n\"1\",\"2\"\n")
+
+	if err = writer.Close(); err != nil {
+		t.Errorf("Close(): error thrown for extra column without CheckHeader: %s", err)
+	}
+
+	if len(d.RecordMaps) != 1 {
+		t.Errorf("Close(): expected 1 record, got %d", len(d.RecordMaps))
+	}
+}
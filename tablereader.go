@@ -0,0 +1,361 @@
+package datadirectory
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts tried, in order, when scanning values into time.Time fields.
+var scanTimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"15:04:05",
+}
+
+// TableReader iterates over the rows of every data file for a table, in
+// metadata order. Compressed files are decompressed on the fly. If
+// VerifyChecksums is set before the first call to Next, each file's checksum
+// is verified as it is read and a mismatch stops the iteration with an error.
+type TableReader struct {
+	VerifyChecksums bool
+
+	d         *DataDirectory
+	records   []map[string]string
+	index     int
+	file      fs.File
+	data      io.ReadCloser
+	raw       io.Reader
+	sum       hash.Hash
+	csvReader *csv.Reader
+	header    []string
+	row       []string
+	firstRow  bool
+	changed   bool
+	err       error
+}
+
+// OpenTable returns a TableReader for the rows of every data file listed for
+// the passed table.
+func (d *DataDirectory) OpenTable(table string) (*TableReader, error) {
+
+	var records []map[string]string
+
+	for _, recordMap := range d.RecordMaps {
+		if recordMap["table"] == table {
+			records = append(records, recordMap)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("table '%s' not listed in metadata", table)
+	}
+
+	return &TableReader{
+		d:       d,
+		records: records,
+	}, nil
+}
+
+// Next advances to the next row, opening the next file for the table as
+// needed. It returns false when there are no more rows or an error occurs,
+// which is then returned by Err.
+func (r *TableReader) Next() bool {
+
+	for r.err == nil {
+
+		var (
+			row []string
+			err error
+		)
+
+		if r.csvReader == nil {
+
+			if r.index >= len(r.records) {
+				return false
+			}
+
+			if r.err = r.openFile(); r.err != nil {
+				return false
+			}
+
+			continue
+		}
+
+		if row, err = r.csvReader.Read(); err == io.EOF {
+			r.err = r.closeFile(true)
+			r.index++
+			continue
+		}
+
+		if err != nil {
+			r.err = fmt.Errorf("file '%s' %s", r.Filename(), err)
+			return false
+		}
+
+		r.row = row
+		r.changed = r.firstRow
+		r.firstRow = false
+
+		return true
+	}
+
+	return false
+}
+
+// openFile opens the current data file and reads its header.
+func (r *TableReader) openFile() error {
+
+	var (
+		recordMap = r.records[r.index]
+		err       error
+	)
+
+	if r.file, err = r.d.openFile(recordMap["filename"]); err != nil {
+		return err
+	}
+
+	r.raw = r.file
+
+	if r.VerifyChecksums {
+		r.sum = sha256.New()
+		r.raw = io.TeeReader(r.file, r.sum)
+	}
+
+	if r.data, err = decompressReader(r.raw, recordCompression(recordMap)); err != nil {
+		r.file.Close()
+		return fmt.Errorf("file '%s' could not be decompressed: %s", recordMap["filename"], err)
+	}
+
+	r.csvReader = csv.NewReader(r.data)
+	r.firstRow = true
+
+	if r.header, err = r.csvReader.Read(); err == io.EOF {
+		r.header = nil
+	} else if err != nil {
+		r.closeFile(false)
+		return fmt.Errorf("file '%s' header could not be read: %s", recordMap["filename"], err)
+	}
+
+	return nil
+}
+
+// closeFile closes the current data file, verifying its checksum if the file
+// was read to the end and VerifyChecksums is set.
+func (r *TableReader) closeFile(complete bool) error {
+
+	var (
+		recordMap = r.records[r.index]
+		err       error
+	)
+
+	if r.csvReader == nil {
+		return nil
+	}
+
+	r.csvReader = nil
+
+	defer r.file.Close()
+
+	if err = r.data.Close(); err != nil {
+		return err
+	}
+
+	if !complete || !r.VerifyChecksums {
+		return nil
+	}
+
+	// Hash anything not consumed by the csv reader.
+	if _, err = io.Copy(io.Discard, r.raw); err != nil {
+		return err
+	}
+
+	if recordMap["checksum"] != hex.EncodeToString(r.sum.Sum(nil)) {
+		return fmt.Errorf("line '%s' file '%s' checksum does not match", recordMap["line"], recordMap["filename"])
+	}
+
+	return nil
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (r *TableReader) Err() error {
+	return r.err
+}
+
+// Close closes the current data file, if any.
+func (r *TableReader) Close() error {
+
+	if r.index < len(r.records) {
+		return r.closeFile(false)
+	}
+
+	return nil
+}
+
+// Filename returns the name of the current data file, relative to the data
+// directory.
+func (r *TableReader) Filename() string {
+
+	if r.index < len(r.records) {
+		return r.records[r.index]["filename"]
+	}
+
+	return ""
+}
+
+// Line returns the line number of the current row in the current data file,
+// counting the header as line 1.
+func (r *TableReader) Line() int {
+
+	if r.csvReader == nil || r.row == nil {
+		return 0
+	}
+
+	line, _ := r.csvReader.FieldPos(0)
+
+	return line
+}
+
+// FileChanged reports whether the current row is the first row of its data
+// file. Since headers may differ between the files of a table, column
+// positions found from the Header must be found again when it does.
+func (r *TableReader) FileChanged() bool {
+	return r.changed
+}
+
+// Columns returns the positions of the passed fields in the header of the
+// current data file, matched case-insensitively.
+func (r *TableReader) Columns(fields []string) ([]int, error) {
+
+	var (
+		columns []int
+		err     error
+	)
+
+	if columns, err = fieldColumns(r.header, fields); err != nil {
+		return nil, fmt.Errorf("file '%s' %s", r.Filename(), err)
+	}
+
+	return columns, nil
+}
+
+// Header returns the header of the current data file.
+func (r *TableReader) Header() []string {
+	return r.header
+}
+
+// Values returns the values of the current row, in header order.
+func (r *TableReader) Values() []string {
+	return r.row
+}
+
+// Row returns the current row as a map of column names to values.
+func (r *TableReader) Row() map[string]string {
+
+	var row = make(map[string]string, len(r.header))
+
+	for i, column := range r.header {
+		row[column] = r.row[i]
+	}
+
+	return row
+}
+
+// Scan copies the current row into the struct pointed to by dst. Columns are
+// matched to fields by a `csv:"name"` tag or, without one, by the lowercased
+// field name. String, integer, float, bool and time.Time fields are
+// supported; empty values leave fields unchanged.
+func (r *TableReader) Scan(dst interface{}) error {
+
+	var (
+		v       reflect.Value
+		columns map[string]int
+	)
+
+	if v = reflect.ValueOf(dst); v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("scan destination must be a pointer to a struct, not %T", dst)
+	}
+
+	v = v.Elem()
+	columns = make(map[string]int, len(r.header))
+
+	for i, column := range r.header {
+		columns[strings.ToLower(column)] = i
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+
+		var (
+			field = v.Type().Field(i)
+			name  = field.Tag.Get("csv")
+		)
+
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		j, ok := columns[name]
+
+		if !ok || r.row[j] == "" {
+			continue
+		}
+
+		if err := setField(v.Field(i), r.row[j]); err != nil {
+			return fmt.Errorf("file '%s' column '%s': %s", r.Filename(), r.header[j], err)
+		}
+	}
+
+	return nil
+}
+
+// setField parses val into the struct field f.
+func setField(f reflect.Value, val string) error {
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, f.Type().Bits())
+		f.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, f.Type().Bits())
+		f.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, f.Type().Bits())
+		f.SetFloat(n)
+		return err
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		f.SetBool(b)
+		return err
+	}
+
+	if f.Type() == reflect.TypeOf(time.Time{}) {
+		for _, layout := range scanTimeLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				f.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time '%s'", val)
+	}
+
+	return fmt.Errorf("unsupported field type %s", f.Type())
+}
@@ -0,0 +1,198 @@
+package datadirectory_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestOpenTable(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		reader *datadirectory.TableReader
+		dir    string
+		b      bytes.Buffer
+		writer *gzip.Writer
+		ids    []int64
+		err    error
+	)
+
+	dir = copyTestData(t)
+
+	// Split provider over a plain and a compressed file.
+	writer = gzip.NewWriter(&b)
+	writer.Write([]byte("provider_id,year_of_birth\n\"30000\",\"1970\"\n"))
+	writer.Close()
+
+	if err = os.WriteFile(filepath.Join(dir, "provider_2.csv.gz"), b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &datadirectory.Config{
+		DataDirPath: dir,
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Model = "pedsnet"
+	d.ModelVersion = "2.1.0"
+
+	if err = d.AddFile("provider_2.csv.gz", "provider"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reader, err = d.OpenTable("provider"); err != nil {
+		t.Fatalf("OpenTable(): error in basic function: %s", err)
+	}
+
+	defer reader.Close()
+
+	reader.VerifyChecksums = true
+
+	for reader.Next() {
+
+		var provider struct {
+			ID          int64 `csv:"provider_id"`
+			YearOfBirth int
+			CareSiteID  string `csv:"care_site_id"`
+		}
+
+		if err = reader.Scan(&provider); err != nil {
+			t.Fatalf("Scan(): error in basic function: %s", err)
+		}
+
+		ids = append(ids, provider.ID)
+	}
+
+	if err = reader.Err(); err != nil {
+		t.Fatalf("Next(): error in basic function: %s", err)
+	}
+
+	if len(ids) != 4 || ids[0] != 25147 || ids[3] != 30000 {
+		t.Errorf("OpenTable(): unexpected provider ids read: %v", ids)
+	}
+
+}
+
+func TestOpenTableChecksumMismatch(t *testing.T) {
+
+	var (
+		cfg    *datadirectory.Config
+		d      *datadirectory.DataDirectory
+		reader *datadirectory.TableReader
+		err    error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath: "test_data",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.ReadMetadataFromFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.RecordMaps[0]["checksum"] = "foo"
+
+	if reader, err = d.OpenTable("location"); err != nil {
+		t.Fatalf("OpenTable(): error in basic function: %s", err)
+	}
+
+	defer reader.Close()
+
+	reader.VerifyChecksums = true
+
+	for reader.Next() {
+		if reader.Row()["location_id"] != "999999999" {
+			t.Errorf("Row(): unexpected row: %v", reader.Row())
+		}
+	}
+
+	if reader.Err() == nil {
+		t.Errorf("Next(): no error thrown for checksum mismatch")
+	}
+
+	if _, err = d.OpenTable("person"); err == nil {
+		t.Errorf("OpenTable(): no error thrown for unlisted table")
+	}
+
+}
+
+func TestTableReaderColumns(t *testing.T) {
+
+	var (
+		cfg     *datadirectory.Config
+		d       *datadirectory.DataDirectory
+		writer  *datadirectory.TableFileWriter
+		reader  *datadirectory.TableReader
+		columns []int
+		changed []int
+		zips    []string
+		err     error
+	)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  t.TempDir(),
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	// Two files for the table, with the columns in different orders.
+	for _, data := range []string{"location_id,zip\n\"1\",\"19104\"\n\"2\",\"19103\"\n", "zip,location_id\n\"19102\",\"3\"\n"} {
+
+		if writer, err = d.CreateTableFile("location"); err != nil {
+			t.Fatal(err)
+		}
+
+		writer.Write([]byte(data))
+
+		if err = writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if reader, err = d.OpenTable("location"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer reader.Close()
+
+	for i := 0; reader.Next(); i++ {
+
+		if reader.FileChanged() {
+
+			changed = append(changed, i)
+
+			if columns, err = reader.Columns([]string{"ZIP"}); err != nil {
+				t.Fatalf("Columns(): error in basic function: %s", err)
+			}
+		}
+
+		zips = append(zips, reader.Values()[columns[0]])
+	}
+
+	if len(changed) != 2 || changed[0] != 0 || changed[1] != 2 {
+		t.Errorf("FileChanged(): expected changes at rows [0 2], got %v", changed)
+	}
+
+	if len(zips) != 3 || zips[2] != "19102" {
+		t.Errorf("Columns(): unexpected values read: %v", zips)
+	}
+
+	if _, err = reader.Columns([]string{"foo"}); err == nil {
+		t.Errorf("Columns(): expected error for missing column")
+	}
+}
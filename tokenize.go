@@ -0,0 +1,276 @@
+package datadirectory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bounds of the number of hex digits of string tokens.
+const (
+	minTokenSize = 16
+	maxTokenSize = 32
+)
+
+// Largest integer tokens of the model integer types. Other integer types are
+// 64 bit.
+var integerTokenMax = map[string]uint64{
+	"integer": math.MaxInt32,
+	"bigint":  math.MaxInt64,
+}
+
+// Tokenize writes a copy of the data files listed in the metadata to a new
+// data directory at dirPath, which must not exist yet, with the values of
+// the passed columns replaced by tokens made with a keyed hash of the
+// secret. Each column is either a bare name, such as "person_id", for that
+// column in every table, or qualified by a table, such as
+// "person.person_source_value". Columns linked to a tokenized column by a
+// foreign key, from the data models service or the DataDirectory
+// ForeignKeys, are tokenized as well, so references remain valid. A value
+// gets the same token in every column and in every run with the same
+// secret, so joins still work. Tokens of integer fields are positive
+// integers that fit the smallest tokenized integer type: 32 bit if any
+// tokenized field is an "integer", 64 bit otherwise. Two values with the same
+// 32 bit token are an error, since the range is small enough for them to
+// collide. Other tokens are 32 hex digits, or as many as the shortest
+// tokenized string field allows, but no fewer than 16. Empty values are left
+// empty.
+// The new directory gets its own metadata.csv and a transforms.json file
+// recording the tokenized columns, but not the secret.
+func (d *DataDirectory) Tokenize(dirPath string, secret []byte, columns []string) (*DataDirectory, error) {
+
+	var (
+		bare      map[string]bool
+		qualified map[string]bool
+		size      int
+		max       uint64
+		tokens    map[string]string
+		record    *TransformRecord
+		err       error
+	)
+
+	if len(secret) == 0 {
+		return nil, errors.New("tokenization requires a secret")
+	}
+
+	if bare, qualified, err = d.tokenColumns(columns); err != nil {
+		return nil, err
+	}
+
+	if size, max, err = d.tokenSize(bare, qualified); err != nil {
+		return nil, err
+	}
+
+	// The values of each 32 bit token, to find collisions.
+	tokens = make(map[string]string)
+
+	record = &TransformRecord{
+		Transform: "tokenize",
+		KeyID:     keyID(secret),
+	}
+
+	return d.transform(dirPath, func(table string, header []string) (func(row []string) (bool, error), error) {
+
+		var (
+			records  = d.RecordsByTable(table)
+			modelDef = d.modelTable(records[0].CDM, records[0].CDMVersion, table)
+			cols     []int
+			integer  []bool
+		)
+
+		for i, column := range header {
+
+			column = strings.ToLower(column)
+
+			if bare[column] || qualified[table+"."+column] {
+				cols = append(cols, i)
+				integer = append(integer, tableSchemaTypes[modelFieldType(modelDef, column)] == "integer")
+				record.appendColumn(table, column)
+			}
+		}
+
+		return func(row []string) (bool, error) {
+
+			for j, i := range cols {
+
+				if i >= len(row) || row[i] == "" {
+					continue
+				}
+
+				if !integer[j] {
+					row[i] = token(secret, row[i], 0, size)
+					continue
+				}
+
+				value := row[i]
+				row[i] = token(secret, value, max, size)
+
+				if max > math.MaxInt32 {
+					continue
+				}
+
+				if other, ok := tokens[row[i]]; ok && other != value {
+					return false, fmt.Errorf("column '%s.%s' has too many values for 32 bit integer tokens", table, strings.ToLower(header[i]))
+				}
+
+				tokens[row[i]] = value
+			}
+
+			return true, nil
+		}, nil
+	}, record)
+}
+
+// tokenColumns returns the bare and table qualified columns to tokenize,
+// lowercased, adding the columns linked to them by foreign keys. Columns
+// that are not fields of any table listed in the metadata are an error.
+func (d *DataDirectory) tokenColumns(columns []string) (map[string]bool, map[string]bool, error) {
+
+	var (
+		bare      = make(map[string]bool)
+		qualified = make(map[string]bool)
+		keys      = d.foreignKeys()
+		changed   = true
+	)
+
+	tokenized := func(column string) bool {
+		_, field, _ := strings.Cut(column, ".")
+		return bare[field] || qualified[column]
+	}
+
+	for _, column := range columns {
+
+		var (
+			name         = strings.ToLower(column)
+			table, field string
+			found        bool
+		)
+
+		if table, field, found = strings.Cut(name, "."); !found {
+			table, field = "", name
+		}
+
+		found = false
+
+		for _, listed := range d.Tables() {
+
+			record := d.RecordsByTable(listed)[0]
+
+			if (table == "" || table == listed) && modelFieldType(d.modelTable(record.CDM, record.CDMVersion, listed), field) != "" {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, nil, fmt.Errorf("column '%s' not found in any table in the data models service", column)
+		}
+
+		if table == "" {
+			bare[name] = true
+		} else {
+			qualified[name] = true
+		}
+	}
+
+	// Tokenize both sides of every foreign key with a tokenized side, until
+	// there are no more changes, since keys may be chained.
+	for changed {
+
+		changed = false
+
+		for _, key := range keys {
+			for i, field := range key.Fields {
+
+				from := strings.ToLower(key.Table + "." + field)
+				to := strings.ToLower(key.ReferencesTable + "." + key.ReferencesFields[i])
+
+				if tokenized(from) != tokenized(to) {
+					qualified[from] = true
+					qualified[to] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	return bare, qualified, nil
+}
+
+// tokenSize returns the number of hex digits of string tokens, the length
+// of the shortest tokenized string field in the data models service up to
+// maxTokenSize, and the largest integer token, that of the smallest
+// tokenized integer type. String fields shorter than minTokenSize are an
+// error, since their tokens would collide too easily.
+func (d *DataDirectory) tokenSize(bare map[string]bool, qualified map[string]bool) (int, uint64, error) {
+
+	var (
+		size = maxTokenSize
+		max  = uint64(math.MaxInt64)
+	)
+
+	for _, table := range d.Tables() {
+
+		record := d.RecordsByTable(table)[0]
+		modelDef := d.modelTable(record.CDM, record.CDMVersion, table)
+
+		if modelDef == nil {
+			continue
+		}
+
+		for _, field := range modelDef.Fields {
+
+			name := strings.ToLower(field.Name)
+
+			if !bare[name] && !qualified[table+"."+name] {
+				continue
+			}
+
+			if typ := strings.ToLower(field.Type); tableSchemaTypes[typ] == "integer" {
+
+				if integerTokenMax[typ] > 0 && integerTokenMax[typ] < max {
+					max = integerTokenMax[typ]
+				}
+
+				continue
+			}
+
+			if field.Length <= 0 || field.Length >= size {
+				continue
+			}
+
+			if field.Length < minTokenSize {
+				return 0, 0, fmt.Errorf("column '%s.%s' length %d is too short for tokens of at least %d characters", table, name, field.Length, minTokenSize)
+			}
+
+			size = field.Length
+		}
+	}
+
+	return size, max, nil
+}
+
+// token returns the token of a value, a positive integer up to max if max is
+// set or size hex digits otherwise.
+func token(secret []byte, value string, max uint64, size int) string {
+
+	var (
+		mac = hmac.New(sha256.New, secret)
+		sum []byte
+	)
+
+	mac.Write([]byte("token\x00" + value))
+	sum = mac.Sum(nil)
+
+	if max > 0 {
+		return strconv.FormatUint(binary.BigEndian.Uint64(sum)%max+1, 10)
+	}
+
+	return hex.EncodeToString(sum)[:size]
+}
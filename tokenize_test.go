@@ -0,0 +1,142 @@
+package datadirectory_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/infomodels/datadirectory"
+)
+
+func TestTokenize(t *testing.T) {
+
+	var (
+		d          *datadirectory.DataDirectory
+		dst        *datadirectory.DataDirectory
+		violations []*datadirectory.ReferenceViolation
+		transforms []*datadirectory.TransformRecord
+		err        error
+	)
+
+	d = personTestData(t)
+
+	if dst, err = d.Tokenize(filepath.Join(t.TempDir(), "tokenized"), []byte("secret"), []string{"person_id", "person.person_source_value"}); err != nil {
+		t.Fatalf("Tokenize(): error in basic function: %s", err)
+	}
+
+	if err = dst.Validate(); err != nil {
+		t.Errorf("Tokenize(): tokenized data directory does not validate: %s", err)
+	}
+
+	people := tableRows(t, dst, "person")
+	visits := tableRows(t, dst, "visit_occurrence")
+
+	if people[0]["person_id"] == "1" || people[0]["person_id"] == people[1]["person_id"] {
+		t.Errorf("Tokenize(): person_id not tokenized: %v", people)
+	}
+
+	if visits[0]["person_id"] != people[0]["person_id"] || visits[1]["person_id"] != people[1]["person_id"] {
+		t.Errorf("Tokenize(): person_id tokens differ across tables")
+	}
+
+	// person_id is an integer, so its tokens fit 32 bits.
+	for _, person := range people {
+		if n, err := strconv.ParseInt(person["person_id"], 10, 64); err != nil || n < 1 || n > math.MaxInt32 {
+			t.Errorf("Tokenize(): person_id token '%s' is not a positive 32 bit integer", person["person_id"])
+		}
+	}
+
+	if people[0]["person_source_value"] == "MRN 1001" || len(people[0]["person_source_value"]) != 32 {
+		t.Errorf("Tokenize(): person_source_value not tokenized: '%s'", people[0]["person_source_value"])
+	}
+
+	if visits[0]["visit_source_value"] != "MRN 1001" || visits[0]["visit_occurrence_id"] != "10" {
+		t.Errorf("Tokenize(): unexpected change to columns not tokenized")
+	}
+
+	if violations, err = dst.ValidateReferences(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, violation := range violations {
+		if violation.ForeignKey.Table == "visit_occurrence" && violation.ForeignKey.ReferencesTable == "person" {
+			t.Errorf("Tokenize(): visit_occurrence references to person broken")
+		}
+	}
+
+	if transforms, err = dst.Transforms(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transforms) != 1 || transforms[0].Transform != "tokenize" || len(transforms[0].Columns["person"]) != 2 {
+		t.Errorf("Tokenize(): unexpected transform record %+v", transforms)
+	}
+
+	if _, err = d.Tokenize(filepath.Join(t.TempDir(), "bogus"), []byte("secret"), []string{"bogus_id"}); err == nil {
+		t.Errorf("Tokenize(): no error thrown for unknown column")
+	}
+}
+
+func TestTokenizeForeignKeys(t *testing.T) {
+
+	var (
+		d   *datadirectory.DataDirectory
+		dst *datadirectory.DataDirectory
+		err error
+	)
+
+	d = personTestData(t)
+
+	// Tokenizing the provider primary key tokenizes references to it.
+	if dst, err = d.Tokenize(filepath.Join(t.TempDir(), "tokenized"), []byte("secret"), []string{"provider.provider_id"}); err != nil {
+		t.Fatalf("Tokenize(): error in basic function: %s", err)
+	}
+
+	providers := tableRows(t, dst, "provider")
+	visits := tableRows(t, dst, "visit_occurrence")
+
+	if providers[0]["provider_id"] == "25147" || visits[0]["provider_id"] != providers[0]["provider_id"] {
+		t.Errorf("Tokenize(): provider_id references not tokenized consistently")
+	}
+}
+
+func TestTokenizeIntegerCollisions(t *testing.T) {
+
+	var (
+		d    *datadirectory.DataDirectory
+		data strings.Builder
+		dir  string
+		err  error
+	)
+
+	d = personTestData(t)
+
+	// Enough persons for two of them to get the same 32 bit token.
+	data.WriteString("person_id,person_source_value\n")
+
+	for i := 1; i <= 200000; i++ {
+		fmt.Fprintf(&data, "\"%d\",\"MRN %d\"\n", i, i)
+	}
+
+	if err = os.WriteFile(filepath.Join(d.DirPath, "person.csv"), []byte(data.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.UpdateChecksum("person.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir = filepath.Join(t.TempDir(), "tokenized")
+
+	if _, err = d.Tokenize(dir, []byte("secret"), []string{"person_id"}); err == nil {
+		t.Errorf("Tokenize(): no error thrown for colliding 32 bit integer tokens")
+	}
+
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Tokenize(): directory with colliding tokens not removed")
+	}
+}
@@ -0,0 +1,241 @@
+package datadirectory
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file recording the transforms applied to a data directory.
+const transformsFile = "transforms.json"
+
+// TransformRecord describes a transform that produced a data directory from
+// a Source directory, as recorded in its transforms.json file. Columns lists
+// the transformed columns of each table. KeyID identifies the secret used,
+// without revealing it, so that outputs made with the same secret can be
+// recognized.
+type TransformRecord struct {
+	Transform  string              `json:"transform"`
+	Time       time.Time           `json:"time"`
+	Source     string              `json:"source"`
+	KeyID      string              `json:"key_id,omitempty"`
+	Parameters map[string]string   `json:"parameters,omitempty"`
+	Columns    map[string][]string `json:"columns,omitempty"`
+}
+
+// rowTransform returns the function applied to each row of a data file of
+// the passed table, with the passed header. The row function changes the row
+// in place and reports whether to keep it.
+type rowTransform func(table string, header []string) (func(row []string) (bool, error), error)
+
+// Transforms returns the transforms recorded in the transforms.json file of
+// the data directory, oldest first, or none if there is no such file.
+func (d *DataDirectory) Transforms() ([]*TransformRecord, error) {
+
+	var (
+		file       fs.File
+		transforms []*TransformRecord
+		err        error
+	)
+
+	if file, err = d.openFile(transformsFile); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&transforms); err != nil {
+		return nil, fmt.Errorf("file '%s' could not be read: %s", transformsFile, err)
+	}
+
+	return transforms, nil
+}
+
+// transform writes the data files listed in the metadata, passed through the
+// row transform, to a new data directory at dirPath, one file for each
+// source file with the same name and compression, verifying their checksums
+// as they are read. The metadata of the new directory is written along with
+// a transforms.json file adding the transform record to those of the source.
+// bzip2 compressed files cannot be written, so sources with them cannot be
+// transformed. If anything fails, the new directory is removed.
+func (d *DataDirectory) transform(dirPath string, transform rowTransform, record *TransformRecord) (*DataDirectory, error) {
+
+	var (
+		dst        *DataDirectory
+		transforms []*TransformRecord
+		data       []byte
+		err        error
+	)
+
+	if transforms, err = d.Transforms(); err != nil {
+		return nil, err
+	}
+
+	if err = os.Mkdir(dirPath, 0755); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dirPath)
+		}
+	}()
+
+	dst = &DataDirectory{
+		RecordMaps:    make([]map[string]string, 0),
+		Site:          d.headerDefault("organization"),
+		Model:         d.headerDefault("cdm"),
+		ModelVersion:  d.headerDefault("cdm-version"),
+		DataVersion:   d.headerDefault("data-version"),
+		Etl:           d.headerDefault("etl"),
+		DirPath:       dirPath,
+		FilePath:      filepath.Join(dirPath, "metadata.csv"),
+		ForeignKeys:   d.ForeignKeys,
+		Logger:        d.Logger,
+		header:        canonicalHeader,
+		service:       d.service,
+		serviceModels: d.serviceModels,
+		serviceTables: d.serviceTables,
+	}
+
+	for _, recordMap := range d.RecordMaps {
+
+		start := time.Now()
+
+		if err = d.transformFile(dst, recordMap, transform); err != nil {
+			return nil, err
+		}
+
+		d.logger().Info("transformed data file", "file", recordMap["filename"], "table", recordMap["table"], "transform", record.Transform, "duration", time.Since(start))
+	}
+
+	record.Time = time.Now().UTC()
+	record.Source = d.DirPath
+
+	if data, err = json.MarshalIndent(append(transforms, record), "", "  "); err != nil {
+		return nil, err
+	}
+
+	if err = os.WriteFile(filepath.Join(dirPath, transformsFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	if err = dst.WriteMetadataToFile(); err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// transformFile writes the data file of a record, passed through the row
+// transform, to a data file of the same name, table and compression in dst.
+// On failure, the partly written file is removed without being recorded.
+func (d *DataDirectory) transformFile(dst *DataDirectory, recordMap map[string]string, transform rowTransform) error {
+
+	var (
+		reader *TableReader
+		writer *TableFileWriter
+		csvw   *csv.Writer
+		apply  func(row []string) (bool, error)
+		more   bool
+		err    error
+	)
+
+	reader = &TableReader{
+		d:               d,
+		records:         []map[string]string{recordMap},
+		VerifyChecksums: true,
+	}
+
+	defer reader.Close()
+
+	// Read the first row, if any, so that the header is known.
+	more = reader.Next()
+
+	if err = reader.Err(); err != nil {
+		return err
+	}
+
+	if reader.Header() == nil {
+		return fmt.Errorf("line '%s' file '%s' has no header", recordMap["line"], recordMap["filename"])
+	}
+
+	if apply, err = transform(recordMap["table"], reader.Header()); err != nil {
+		return fmt.Errorf("file '%s' %s", recordMap["filename"], err)
+	}
+
+	if writer, err = dst.createTableFile(recordMap["table"], recordMap["filename"], recordCompression(recordMap)); err != nil {
+		return err
+	}
+
+	csvw = csv.NewWriter(writer)
+	csvw.Write(reader.Header())
+
+	for ; more; more = reader.Next() {
+
+		var (
+			row  = append([]string(nil), reader.Values()...)
+			keep bool
+		)
+
+		if keep, err = apply(row); err != nil {
+			writer.Abort()
+			return fmt.Errorf("file '%s' line %d %s", recordMap["filename"], reader.Line(), err)
+		}
+
+		if keep {
+			csvw.Write(row)
+		}
+	}
+
+	if err = reader.Err(); err != nil {
+		writer.Abort()
+		return err
+	}
+
+	csvw.Flush()
+
+	if err = csvw.Error(); err != nil {
+		writer.Abort()
+		return err
+	}
+
+	return writer.Close()
+}
+
+// keyID returns an identifier of a secret that does not reveal it.
+func keyID(secret []byte) string {
+
+	var mac = hmac.New(sha256.New, secret)
+
+	mac.Write([]byte("key-id"))
+
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// appendColumn adds a column to the list of transformed columns of a table
+// in a transform record, if it is not already there.
+func (r *TransformRecord) appendColumn(table string, column string) {
+
+	if r.Columns == nil {
+		r.Columns = make(map[string][]string)
+	}
+
+	for _, existing := range r.Columns[table] {
+		if existing == column {
+			return
+		}
+	}
+
+	r.Columns[table] = append(r.Columns[table], column)
+}
@@ -1,14 +1,14 @@
 package datadirectory
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"hash"
-	"io"
-	"log"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"strconv"
+	"time"
 )
 
 // Validate checks the validity of the DataDirectory object. Specifically, the
@@ -17,8 +17,19 @@ import (
 // service. If all of those checks pass, then each checksum is checked for
 // accuracy.
 func (d *DataDirectory) Validate() error {
+	return d.ValidateContext(context.Background())
+}
+
+// ValidateContext is Validate, stopping with the context error once ctx is
+// done and reporting the progress of checking the checksums to the
+// DataDirectory Progress attribute, if set.
+func (d *DataDirectory) ValidateContext(ctx context.Context) error {
 
-	var err error
+	var (
+		total   int64
+		tracker *progressTracker
+		err     error
+	)
 
 	// Validate records values, except for checksums.
 	for _, recordMap := range d.RecordMaps {
@@ -101,31 +112,92 @@ func (d *DataDirectory) Validate() error {
 		if d.DataVersion != "" && recordMap["data-version"] != "" && recordMap["data-version"] != d.DataVersion {
 			return fmt.Errorf("line '%s' data-version '%s' does not match expected data version '%s'", recordMap["line"], recordMap["data-version"], d.DataVersion)
 		}
+
+		// Check that the compression, if present, is supported.
+		if err = checkCompression(recordMap["compression"]); err != nil {
+			return fmt.Errorf("line '%s' %s", recordMap["line"], err)
+		}
+
+		// Check that the row count and size, if present, are numbers.
+		for _, val := range []string{"row-count", "size"} {
+			if recordMap[val] == "" {
+				continue
+			}
+			if n, err := strconv.ParseInt(recordMap[val], 10, 64); err != nil || n < 0 {
+				return fmt.Errorf("line '%s' %s '%s' is not a valid number", recordMap["line"], val, recordMap[val])
+			}
+		}
 	}
 
+	// Total the data file sizes for progress reports. Missing files are
+	// reported below.
+	for _, recordMap := range d.RecordMaps {
+		if name, err := fsPath(recordMap["filename"]); err == nil {
+			if fi, err := fs.Stat(d.fsys(), name); err == nil {
+				total += fi.Size()
+			}
+		}
+	}
+
+	tracker = newProgressTracker(ctx, d.Progress, total)
+
 	// Validate record checksums.
 	for _, recordMap := range d.RecordMaps {
 
 		var (
-			dataFile  *os.File
+			dataFile  fs.File
+			fi        fs.FileInfo
 			sum       hash.Hash
+			rows      *csvRowCounter
 			sumString string
+			start     time.Time
 		)
 
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
 		// Check that file exists.
-		if dataFile, err = os.Open(filepath.Join(d.DirPath, recordMap["filename"])); err != nil {
+		if dataFile, err = d.openFile(recordMap["filename"]); err != nil {
 			return err
 		}
 
-		defer dataFile.Close()
+		if fi, err = dataFile.Stat(); err != nil {
+			dataFile.Close()
+			return err
+		}
 
-		// Verify checksum.
+		// Verify checksum, counting rows in the same pass. Compressed files are
+		// decompressed to check their integrity and count their rows.
 		sum = sha256.New()
+		rows = &csvRowCounter{}
+		start = time.Now()
 
-		log.Printf("packer: validating '%s' checksum", filepath.Base(recordMap["filename"]))
+		tracker.startFile(recordMap["filename"])
 
-		if _, err = io.Copy(sum, dataFile); err != nil {
-			return err
+		err = hashDataFile(tracker.reader(dataFile), sum, rows, recordCompression(recordMap))
+		dataFile.Close()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			return fmt.Errorf("line '%s' file '%s' could not be read: %s", recordMap["line"], recordMap["filename"], err)
+		}
+
+		tracker.finishFile()
+
+		d.logger().Info("validated checksum", "file", recordMap["filename"], "table", recordMap["table"], "bytes", fi.Size(), "duration", time.Since(start))
+
+		// Check the row count and size, if present, before the checksum, so
+		// that truncated files are reported as such.
+		if recordMap["row-count"] != "" && recordMap["row-count"] != strconv.FormatInt(dataRows(rows), 10) {
+			return fmt.Errorf("line '%s' file '%s' expected %s rows, found %d", recordMap["line"], recordMap["filename"], recordMap["row-count"], dataRows(rows))
+		}
+
+		if recordMap["size"] != "" && recordMap["size"] != strconv.FormatInt(fi.Size(), 10) {
+			return fmt.Errorf("line '%s' file '%s' expected %s bytes, found %d", recordMap["line"], recordMap["filename"], recordMap["size"], fi.Size())
 		}
 
 		sumString = hex.EncodeToString(sum.Sum(nil))
@@ -1,6 +1,9 @@
 package datadirectory_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/infomodels/datadirectory"
@@ -314,3 +317,57 @@ func TestValidateMismatchChecksum(t *testing.T) {
 	}
 
 }
+
+func TestValidateTruncated(t *testing.T) {
+
+	var (
+		cfg  *datadirectory.Config
+		d    *datadirectory.DataDirectory
+		dir  string
+		data []byte
+		err  error
+	)
+
+	dir = copyTestData(t)
+
+	cfg = &datadirectory.Config{
+		DataDirPath:  dir,
+		Model:        "pedsnet",
+		ModelVersion: "2.1.0",
+		Site:         "org",
+		DataVersion:  "3",
+		Etl:          "https://persistentcodestorage.com/ETLScript3.sql",
+	}
+
+	d, _ = datadirectory.New(cfg)
+
+	if err = d.PopulateMetadataFromData(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the last row of the provider file.
+	if data, err = os.ReadFile(filepath.Join(dir, "provider.csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	data = data[:strings.LastIndex(strings.TrimRight(string(data), "\n"), "\n")+1]
+
+	if err = os.WriteFile(filepath.Join(dir, "provider.csv"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = d.Validate(); err == nil || !strings.Contains(err.Error(), "expected 3 rows, found 2") {
+		t.Errorf("Validate(): expected row count error for truncated file, got %v", err)
+	}
+
+	// A size mismatch is reported when the row count matches.
+	for _, recordMap := range d.RecordMaps {
+		if recordMap["filename"] == "provider.csv" {
+			recordMap["row-count"] = "2"
+		}
+	}
+
+	if err = d.Validate(); err == nil || !strings.Contains(err.Error(), "bytes, found") {
+		t.Errorf("Validate(): expected size error for truncated file, got %v", err)
+	}
+}
@@ -1,41 +1,66 @@
 package datadirectory
 
 import (
-	"fmt"
+	"bytes"
 	"io"
 	"os"
-	"strings"
 )
 
 // WriteMetadataToFile writes data from the DataDirectory object to the
-// metadata.csv file. An existing metadata.csv will be overwritten.
+// metadata file. An existing metadata file will be overwritten. The format
+// is chosen by the FilePath extension, defaulting to csv. If the FS
+// attribute supports writing files, as an ObjectFS does, the metadata file
+// is written through it.
 func (d *DataDirectory) WriteMetadataToFile() error {
 
 	var (
-		file io.Writer
+		file *os.File
 		err  error
 	)
 
-	if file, err = os.OpenFile(d.FilePath, os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+	if name, ok := d.metadataPath(); ok {
+		if wfs, ok := d.fsys().(writeFileFS); ok {
+
+			var buf bytes.Buffer
+
+			if err = d.WriteMetadataFormat(&buf, manifestFormatFromPath(d.FilePath)); err != nil {
+				return err
+			}
+
+			return wfs.WriteFile(name, buf.Bytes())
+		}
+	}
+
+	if file, err = os.OpenFile(d.FilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644); err != nil {
 		return err
 	}
 
-	if err = d.WriteMetadata(file); err != nil {
+	if err = d.WriteMetadataFormat(file, manifestFormatFromPath(d.FilePath)); err != nil {
+		file.Close()
 		return err
 	}
 
-	return nil
+	return file.Close()
 
 }
 
 // WriteMetadata writes metadata.csv-style data from the DataDirectory object
 // to the passed writer.
 func (d *DataDirectory) WriteMetadata(w io.Writer) error {
+	return d.WriteMetadataFormat(w, CSVFormat)
+}
 
-	var err error
+// WriteMetadataFormat writes metadata in the passed format from the
+// DataDirectory object to the passed writer.
+func (d *DataDirectory) WriteMetadataFormat(w io.Writer, format ManifestFormat) error {
 
-	// Write metadata header.
-	if _, err = w.Write([]byte(fmt.Sprintf("\"%s\"\n", strings.Join(d.header, `","`)))); err != nil {
+	var (
+		codec manifestCodec
+		rows  [][]string
+		err   error
+	)
+
+	if codec, err = manifestCodecFor(format); err != nil {
 		return err
 	}
 
@@ -44,10 +69,8 @@ func (d *DataDirectory) WriteMetadata(w io.Writer) error {
 		for _, val := range d.header {
 			row = append(row, record[val])
 		}
-		if _, err = w.Write([]byte(fmt.Sprintf("\"%s\"\n", strings.Join(row, `","`)))); err != nil {
-			return err
-		}
+		rows = append(rows, row)
 	}
 
-	return nil
+	return codec.encode(w, d.header, rows)
 }